@@ -1,12 +1,16 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime/debug"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -26,6 +30,8 @@ type App struct {
 	watcher    *fsnotify.Watcher
 	checkChan  chan struct{} // 触发检测的通道
 	checking   atomic.Bool   // 检测状态标志
+	cancelMu   sync.Mutex
+	cancel     context.CancelFunc // 取消正在进行的检测，没有检测时为 nil
 	ticker     *time.Ticker
 	done       chan struct{} // 用于结束ticker goroutine的信号
 	cron       *cron.Cron    // crontab调度器
@@ -68,6 +74,10 @@ func (app *App) Initialize() error {
 		os.Setenv("HTTPS_PROXY", config.GlobalConfig.Proxy)
 	}
 
+	// 本地没有输出时从远程后端恢复，避免启动后订阅为空
+	// 在后台执行，远程存储响应慢时不影响启动
+	go save.RestoreOutputs()
+
 	app.interval = config.GlobalConfig.CheckInterval
 
 	if config.GlobalConfig.ListenPort != "" {
@@ -80,7 +90,7 @@ func (app *App) Initialize() error {
 	monitor.StartMemoryMonitor()
 
 	// 设置信号处理器
-	utils.SetupSignalHandler(&check.ForceClose)
+	utils.SetupSignalHandler(func() { app.CancelCheck() })
 	return nil
 }
 
@@ -100,7 +110,11 @@ func (app *App) Run() {
 	app.setTimer()
 
 	// 仅在cron表达式为空时，首次启动立即执行检测
-	if config.GlobalConfig.CronExpression != "" {
+	// 有上一次中断的检测时总是立即继续
+	if check.HasCheckpoint() {
+		slog.Info("发现上一次中断的检测，立即继续")
+		app.triggerCheck()
+	} else if config.GlobalConfig.CronExpression != "" {
 		slog.Warn("使用cron表达式，首次启动不立即执行检测")
 	} else {
 		app.triggerCheck()
@@ -178,6 +192,25 @@ func (app *App) TriggerCheck() {
 	}
 }
 
+// CancelCheck 取消正在进行的检测，没有检测时返回 false
+func (app *App) CancelCheck() bool {
+	app.cancelMu.Lock()
+	defer app.cancelMu.Unlock()
+	if app.cancel == nil {
+		return false
+	}
+	app.cancel()
+	slog.Warn("已取消正在进行的检测")
+	return true
+}
+
+// setCancel 记录当前检测的取消函数
+func (app *App) setCancel(cancel context.CancelFunc) {
+	app.cancelMu.Lock()
+	defer app.cancelMu.Unlock()
+	app.cancel = cancel
+}
+
 // triggerCheck 内部检测方法
 func (app *App) triggerCheck() {
 	// 如果已经在检测中，直接返回
@@ -187,7 +220,14 @@ func (app *App) triggerCheck() {
 	}
 	defer app.checking.Store(false)
 
-	if err := app.checkProxies(); err != nil {
+	ctx, cancel := context.WithCancel(context.Background())
+	app.setCancel(cancel)
+	defer func() {
+		app.setCancel(nil)
+		cancel()
+	}()
+
+	if err := app.checkProxies(ctx); err != nil {
 		slog.Error(fmt.Sprintf("检测代理失败: %v", err))
 		os.Exit(1)
 	}
@@ -210,24 +250,35 @@ func (app *App) triggerCheck() {
 }
 
 // checkProxies 执行代理检测
-func (app *App) checkProxies() error {
+// 检测被取消时保存已完成的检测结果，还没有结果时不保存，避免覆盖上一次的输出
+func (app *App) checkProxies(ctx context.Context) error {
 	slog.Info("开始准备检测代理", "进度展示", config.GlobalConfig.PrintProgress)
 
-	results, err := check.Check()
-	if err != nil {
+	results, err := check.Check(ctx)
+	if errors.Is(err, context.Canceled) {
+		if len(results) == 0 {
+			slog.Warn(fmt.Sprintf("%v，没有可保存的结果", err))
+			return nil
+		}
+		slog.Warn(fmt.Sprintf("%v，保存已完成的检测结果", err))
+	} else if err != nil {
 		return fmt.Errorf("检测代理失败: %w", err)
 	}
 	// 将成功的节点添加到全局中，暂时内存保存
+	// 复制一份，下一次检测重命名节点时不会修改 /api/nodes 正在读取的结果
 	if config.GlobalConfig.KeepSuccessProxies {
 		for _, result := range results {
 			if result.Proxy != nil {
-				config.GlobalProxies = append(config.GlobalProxies, result.Proxy)
+				config.GlobalProxies = append(config.GlobalProxies, maps.Clone(result.Proxy))
 			}
 		}
 	}
 
 	slog.Info("检测完成")
-	save.SaveConfig(results)
+	// 发布保护跳过保存时已经发送了警告，不再发送通知和执行回调
+	if err := save.SaveConfig(results); errors.Is(err, save.ErrPublishBlocked) {
+		return nil
+	}
 	utils.SendNotify(len(results))
 
 	// 执行回调脚本
@@ -0,0 +1,275 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/beck-8/subs-check/check"
+	"github.com/beck-8/subs-check/config"
+	proxyutils "github.com/beck-8/subs-check/proxy"
+	"github.com/beck-8/subs-check/save"
+	"github.com/beck-8/subs-check/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/metacubex/mihomo/common/convert"
+)
+
+const (
+	defaultNodePageSize = 50
+	maxNodePageSize     = 500
+)
+
+// supportedPlatforms 支持检测的平台
+var supportedPlatforms = []string{"openai", "youtube", "netflix", "disney", "gemini", "iprisk", "tiktok"}
+
+// checkNodeSlots 限制同时进行的单独检测数量
+var checkNodeSlots = make(chan struct{}, 4)
+
+// secretProxyFields 节点配置中需要隐藏的字段
+var secretProxyFields = map[string]bool{
+	"password":       true,
+	"uuid":           true,
+	"auth":           true,
+	"auth-str":       true,
+	"auth_str":       true,
+	"obfs-password":  true,
+	"private-key":    true,
+	"pre-shared-key": true,
+	"psk":            true,
+	"token":          true,
+	"username":       true,
+	"short-id":       true,
+}
+
+// nodeQuery /api/nodes 的查询参数
+type nodeQuery struct {
+	Country      string `form:"country"`
+	Type         string `form:"type"`
+	Platform     string `form:"platform"`
+	Subscription string `form:"subscription"`
+	Alive        string `form:"alive"` // true 只返回可用节点，false 只返回失败节点
+	Sort         string `form:"sort"`  // speed(默认，降序) 或 latency(升序)
+	Page         int    `form:"page"`
+	Size         int    `form:"size"`
+}
+
+// latestResults 上一次检测的全部节点，可用节点在前
+func latestResults() []check.Result {
+	run := check.LastRun()
+	if run == nil {
+		return nil
+	}
+	return slices.Concat(run.Results, run.Failed)
+}
+
+// getNodes 分页查询上一次检测的节点
+func (app *App) getNodes(c *gin.Context) {
+	var query nodeQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的查询参数"})
+		return
+	}
+	if query.Sort != "" && query.Sort != "speed" && query.Sort != "latency" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort 只支持 speed 和 latency"})
+		return
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Size < 1 {
+		query.Size = defaultNodePageSize
+	}
+	query.Size = min(query.Size, maxNodePageSize)
+
+	nodes := make([]save.NodeReport, 0)
+	for _, result := range latestResults() {
+		if node := save.NewNodeReport(result); query.match(node) {
+			nodes = append(nodes, node)
+		}
+	}
+	sortNodes(nodes, query.Sort)
+
+	total := len(nodes)
+	start := min((query.Page-1)*query.Size, total)
+	end := min(start+query.Size, total)
+	c.JSON(http.StatusOK, gin.H{
+		"total": total,
+		"page":  query.Page,
+		"size":  query.Size,
+		"nodes": nodes[start:end],
+	})
+}
+
+// getNode 获取单个节点的完整检测结果，节点配置中的密码等字段会被隐藏
+func (app *App) getNode(c *gin.Context) {
+	id := c.Param("id")
+	for _, result := range latestResults() {
+		if proxyutils.ProxyID(result.Proxy) != id {
+			continue
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"node":  save.NewNodeReport(result),
+			"proxy": maskProxy(result.Proxy),
+		})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "节点不存在"})
+}
+
+// checkNodeRequest /api/check-node 的请求，proxy、link 和 id 三选一
+type checkNodeRequest struct {
+	Proxy     map[string]any `json:"proxy"`     // mihomo 格式的节点配置
+	Link      string         `json:"link"`      // 分享链接，如 vmess://、vless://、ss://
+	ID        string         `json:"id"`        // 上一次检测结果中的节点ID
+	Platforms *[]string      `json:"platforms"` // 需要检测的平台，不填时使用配置中的平台
+}
+
+// checkNode 单独检测一个节点，同步返回检测结果，不影响正在进行的检测和已发布的订阅
+func (app *App) checkNode(c *gin.Context) {
+	var req checkNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求格式"})
+		return
+	}
+
+	proxy, err := req.resolveProxy()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	platforms, err := resolvePlatforms(req.Platforms)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	select {
+	case checkNodeSlots <- struct{}{}:
+		defer func() { <-checkNodeSlots }()
+	default:
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "单独检测的请求过多，请稍后再试"})
+		return
+	}
+
+	result, traffic := check.CheckNode(c.Request.Context(), proxy, platforms)
+	c.JSON(http.StatusOK, gin.H{
+		"node":    save.NewNodeReport(result),
+		"traffic": traffic,
+	})
+}
+
+// resolvePlatforms 校验请求中的平台列表，没有指定时使用配置中的平台
+func resolvePlatforms(requested *[]string) ([]string, error) {
+	if requested == nil {
+		if config.GlobalConfig.MediaCheck {
+			return config.GlobalConfig.Platforms, nil
+		}
+		return nil, nil
+	}
+	for _, plat := range *requested {
+		if !slices.Contains(supportedPlatforms, plat) {
+			return nil, fmt.Errorf("不支持的平台: %s", plat)
+		}
+	}
+	return *requested, nil
+}
+
+// resolveProxy 根据请求获取需要检测的节点配置
+func (req checkNodeRequest) resolveProxy() (map[string]any, error) {
+	switch {
+	case req.Proxy != nil:
+		return req.Proxy, nil
+	case req.Link != "":
+		proxies, err := convert.ConvertsV2Ray([]byte(req.Link))
+		if err != nil {
+			return nil, fmt.Errorf("无法解析分享链接: %w", err)
+		}
+		if len(proxies) == 0 {
+			return nil, fmt.Errorf("分享链接中没有节点")
+		}
+		return proxies[0], nil
+	case req.ID != "":
+		for _, result := range latestResults() {
+			if proxyutils.ProxyID(result.Proxy) == req.ID {
+				return result.Proxy, nil
+			}
+		}
+		return nil, fmt.Errorf("节点不存在: %s", req.ID)
+	}
+	return nil, fmt.Errorf("需要提供 proxy、link 或 id")
+}
+
+// match 判断节点是否满足查询条件
+func (q nodeQuery) match(node save.NodeReport) bool {
+	switch {
+	case q.Country != "" && !strings.EqualFold(node.Country, q.Country):
+		return false
+	case q.Type != "" && !strings.EqualFold(node.Type, q.Type):
+		return false
+	case q.Platform != "" && !slices.Contains(node.Platforms, strings.ToLower(q.Platform)):
+		return false
+	case q.Subscription != "" && node.Subscription != q.Subscription:
+		return false
+	case q.Alive == "true" && !node.Passed, q.Alive == "false" && node.Passed:
+		return false
+	}
+	return true
+}
+
+// sortNodes 按速度降序或延迟升序排序，没有结果的节点排在最后
+func sortNodes(nodes []save.NodeReport, by string) {
+	switch by {
+	case "", "speed":
+		slices.SortStableFunc(nodes, func(a, b save.NodeReport) int {
+			return b.Speed - a.Speed
+		})
+	case "latency":
+		slices.SortStableFunc(nodes, func(a, b save.NodeReport) int {
+			switch {
+			case a.Latency == b.Latency:
+				return 0
+			case a.Latency == 0:
+				return 1
+			case b.Latency == 0:
+				return -1
+			}
+			return a.Latency - b.Latency
+		})
+	}
+}
+
+// maskProxy 复制节点配置并隐藏密码等字段，订阅链接替换为订阅名称
+func maskProxy(proxy map[string]any) map[string]any {
+	masked := make(map[string]any, len(proxy))
+	for key, value := range proxy {
+		switch {
+		case key == "sub_url":
+			if subURL, ok := value.(string); ok {
+				tag, _ := proxy["sub_tag"].(string)
+				masked[key] = utils.SubscriptionName(subURL, tag)
+			}
+		case secretProxyFields[key]:
+			masked[key] = "******"
+		default:
+			masked[key] = maskValue(value)
+		}
+	}
+	return masked
+}
+
+// maskValue 递归处理嵌套的配置，例如 plugin-opts 中的 password
+func maskValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		return maskProxy(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = maskValue(item)
+		}
+		return out
+	}
+	return value
+}
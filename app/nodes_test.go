@@ -0,0 +1,98 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/beck-8/subs-check/save"
+)
+
+func TestMaskProxy(t *testing.T) {
+	proxy := map[string]any{
+		"name":        "HK",
+		"server":      "1.1.1.1",
+		"password":    "secret",
+		"sub_url":     "https://example.com/sub?token=secret",
+		"plugin-opts": map[string]any{"mode": "tls", "password": "secret"},
+		"ws-opts":     map[string]any{"headers": map[string]any{"Host": "a.com"}},
+	}
+	masked := maskProxy(proxy)
+
+	if masked["password"] != "******" || masked["server"] != "1.1.1.1" {
+		t.Errorf("maskProxy() = %v", masked)
+	}
+	if masked["sub_url"] != "example.com" {
+		t.Errorf("sub_url = %v, want example.com", masked["sub_url"])
+	}
+	if opts := masked["plugin-opts"].(map[string]any); opts["password"] != "******" || opts["mode"] != "tls" {
+		t.Errorf("plugin-opts = %v", opts)
+	}
+	if proxy["password"] != "secret" {
+		t.Errorf("maskProxy() 修改了原始节点")
+	}
+}
+
+func TestNodeQuery(t *testing.T) {
+	nodes := []save.NodeReport{
+		{ID: "a", Type: "vmess", Country: "HK", Passed: true, Latency: 300, Speed: 100, Platforms: []string{"netflix"}},
+		{ID: "b", Type: "ss", Country: "JP", Passed: true, Latency: 100, Speed: 500},
+		{ID: "c", Type: "ss", Reason: "dial-timeout"},
+	}
+
+	tests := []struct {
+		name  string
+		query nodeQuery
+		want  []string
+	}{
+		{name: "默认按速度", query: nodeQuery{}, want: []string{"b", "a", "c"}},
+		{name: "按延迟", query: nodeQuery{Sort: "latency"}, want: []string{"b", "a", "c"}},
+		{name: "国家", query: nodeQuery{Country: "hk"}, want: []string{"a"}},
+		{name: "协议和可用", query: nodeQuery{Type: "ss", Alive: "true"}, want: []string{"b"}},
+		{name: "失败", query: nodeQuery{Alive: "false"}, want: []string{"c"}},
+		{name: "平台", query: nodeQuery{Platform: "Netflix"}, want: []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []save.NodeReport
+			for _, node := range nodes {
+				if tt.query.match(node) {
+					got = append(got, node)
+				}
+			}
+			sortNodes(got, tt.query.Sort)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d nodes, want %v", len(got), tt.want)
+			}
+			for i, node := range got {
+				if node.ID != tt.want[i] {
+					t.Errorf("node[%d] = %s, want %s", i, node.ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResolveProxy(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      checkNodeRequest
+		wantType string
+		wantErr  bool
+	}{
+		{name: "节点配置", req: checkNodeRequest{Proxy: map[string]any{"type": "trojan"}}, wantType: "trojan"},
+		{name: "分享链接", req: checkNodeRequest{Link: "ss://YWVzLTI1Ni1nY206cGFzcw@1.2.3.4:8388#test"}, wantType: "ss"},
+		{name: "无效链接", req: checkNodeRequest{Link: "not a link"}, wantErr: true},
+		{name: "节点不存在", req: checkNodeRequest{ID: "missing"}, wantErr: true},
+		{name: "空请求", req: checkNodeRequest{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, err := tt.req.resolveProxy()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveProxy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && proxy["type"] != tt.wantType {
+				t.Errorf("type = %v, want %s", proxy["type"], tt.wantType)
+			}
+		})
+	}
+}
@@ -3,16 +3,25 @@ package app
 import (
 	"bufio"
 	"crypto/subtle"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/beck-8/subs-check/check"
 	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/events"
+	"github.com/beck-8/subs-check/metrics"
+	"github.com/beck-8/subs-check/save"
 	"github.com/beck-8/subs-check/save/method"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"gopkg.in/yaml.v3"
 )
@@ -66,6 +75,21 @@ func (app *App) initHttpServer() error {
 
 			// 日志相关API
 			api.GET("/logs", app.getLogs)
+			// 实时事件
+			api.GET("/events", app.streamEvents)
+
+			// 运行报告
+			api.GET("/report", app.getReport)
+			// 节点查询
+			api.GET("/nodes", app.getNodes)
+			api.GET("/nodes/:id", app.getNode)
+			api.POST("/check-node", app.checkNode)
+			api.POST("/check-subscription", app.checkSubscription)
+
+			// 快照相关API
+			api.GET("/snapshots", app.getSnapshots)
+			api.GET("/snapshots/diff", app.diffSnapshots)
+			api.POST("/snapshots/:id/rollback", app.rollbackSnapshot)
 		}
 
 		// 配置页面
@@ -82,6 +106,13 @@ func (app *App) initHttpServer() error {
 		slog.Info("Web控制面板已禁用")
 	}
 
+	// Prometheus 指标，配置了 api-key 时需要认证
+	if config.GlobalConfig.EnableMetrics {
+		app.registerLiveMetrics()
+		router.GET("/metrics", app.metricsAuthMiddleware(config.GlobalConfig.APIKey), gin.WrapH(metrics.Handler()))
+		slog.Info("启用Prometheus指标", "path", "http://ip:port/metrics")
+	}
+
 	// 启动HTTP服务器
 	go func() {
 		for {
@@ -107,6 +138,55 @@ func (app *App) authMiddleware(key string) gin.HandlerFunc {
 	}
 }
 
+// metricsAuthMiddleware 指标认证中间件，支持 X-API-Key 和 Authorization: Bearer 两种方式
+// 方便 Prometheus 使用 bearer_token 抓取
+func (app *App) metricsAuthMiddleware(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key == "" {
+			c.Next()
+			return
+		}
+		apiKey := c.GetHeader("X-API-Key")
+		if apiKey == "" {
+			apiKey = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(key)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "无效的API密钥"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// eventsHeartbeat SSE 保持连接的间隔，避免被反向代理断开
+var eventsHeartbeat = 15 * time.Second
+
+var liveMetricsOnce sync.Once
+
+// registerLiveMetrics 注册检测过程中实时变化的指标
+func (app *App) registerLiveMetrics() {
+	liveMetricsOnce.Do(func() {
+		metrics.NewGaugeFunc("subs_check_checking", "是否正在检测，1 为正在检测", func() float64 {
+			if app.checking.Load() {
+				return 1
+			}
+			return 0
+		})
+		metrics.NewGaugeFunc("subs_check_progress_nodes", "本次检测已完成的节点数量", func() float64 {
+			return float64(check.Progress.Load())
+		})
+		metrics.NewGaugeFunc("subs_check_pending_nodes", "本次检测需要检测的节点数量", func() float64 {
+			return float64(check.ProxyCount.Load())
+		})
+		metrics.NewGaugeFunc("subs_check_available_nodes", "本次检测当前可用的节点数量", func() float64 {
+			return float64(check.Available.Load())
+		})
+		metrics.NewGaugeFunc("subs_check_run_traffic_bytes", "本次检测当前消耗的流量(字节)", func() float64 {
+			return float64(check.TotalBytes.Load())
+		})
+	})
+}
+
 // getConfig 获取配置文件内容
 func (app *App) getConfig(c *gin.Context) {
 	configData, err := os.ReadFile(app.configPath)
@@ -154,6 +234,7 @@ func (app *App) getStatus(c *gin.Context) {
 		"proxyCount": check.ProxyCount.Load(),
 		"available":  check.Available.Load(),
 		"progress":   check.Progress.Load(),
+		"failures":   check.LastFailures(),
 	})
 }
 
@@ -163,9 +244,12 @@ func (app *App) triggerCheckHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "已触发检测"})
 }
 
-// forceCloseHandler 强制关闭
+// forceCloseHandler 强制关闭，取消正在进行的检测
 func (app *App) forceCloseHandler(c *gin.Context) {
-	check.ForceClose.Store(true)
+	if !app.CancelCheck() {
+		c.JSON(http.StatusOK, gin.H{"message": "当前没有正在进行的检测"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "已强制关闭"})
 }
 
@@ -186,6 +270,113 @@ func (app *App) getLogs(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"logs": lines})
 }
 
+// streamEvents 以 SSE 推送检测事件，定时发送注释保持连接
+func (app *App) streamEvents(c *gin.Context) {
+	ch, unsubscribe := events.Subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return false
+			}
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatUint(event.ID, 10),
+				Event: event.Type,
+				Data:  event,
+			})
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// getReport 获取最近一次检测的运行报告
+func (app *App) getReport(c *gin.Context) {
+	data, err := save.ReadReport()
+	if errors.Is(err, method.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "还没有运行报告"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("读取运行报告失败: %v", err)})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// getSnapshots 列出输出快照
+func (app *App) getSnapshots(c *gin.Context) {
+	snapshots, err := save.ListSnapshots()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("读取快照失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"snapshots": snapshots})
+}
+
+// diffSnapshots 比较两个快照，to 为空时与最新快照比较
+func (app *App) diffSnapshots(c *gin.Context) {
+	from, to := c.Query("from"), c.Query("to")
+	if to == "" {
+		snapshots, err := save.ListSnapshots()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("读取快照失败: %v", err)})
+			return
+		}
+		if len(snapshots) > 0 {
+			to = snapshots[0].ID
+		}
+	}
+
+	diff, err := save.DiffSnapshots(from, to)
+	if errors.Is(err, save.ErrSnapshotNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("比较快照失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// rollbackSnapshot 回滚到指定快照并重新发布
+// 回滚期间占用检测状态，避免与检测同时保存
+func (app *App) rollbackSnapshot(c *gin.Context) {
+	if !app.checking.CompareAndSwap(false, true) {
+		c.JSON(http.StatusConflict, gin.H{"error": "正在检测中，请稍后再回滚"})
+		return
+	}
+	defer app.checking.Store(false)
+
+	err := save.RollbackSnapshot(c.Param("id"))
+	if errors.Is(err, save.ErrSnapshotNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("回滚失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已回滚"})
+}
+
 // getLogs 获取最近日志
 func (app *App) getVersion(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"version": app.version})
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	u "net/url"
+	"time"
+
+	"github.com/beck-8/subs-check/check"
+	proxyutils "github.com/beck-8/subs-check/proxy"
+	"github.com/beck-8/subs-check/save"
+	"github.com/beck-8/subs-check/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// checkSubscriptionSlot 同一时间只允许一个订阅试检测
+var checkSubscriptionSlot = make(chan struct{}, 1)
+
+// checkSubscriptionRequest /api/check-subscription 的请求，url 和 content 二选一
+type checkSubscriptionRequest struct {
+	URL       string    `json:"url"`       // 订阅链接
+	Content   string    `json:"content"`   // 订阅内容，mihomo yaml 或 v2ray 格式
+	Platforms *[]string `json:"platforms"` // 需要检测的平台，不填时使用配置中的平台
+}
+
+// checkSubscription 试检测一个订阅并返回汇总和每个节点的结果
+// 使用独立的检测器，不影响定时检测，也不会保存或发布任何文件
+func (app *App) checkSubscription(c *gin.Context) {
+	var req checkSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求格式"})
+		return
+	}
+	if (req.URL == "") == (req.Content == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "需要提供 url 或 content 其中一个"})
+		return
+	}
+	platforms, err := resolvePlatforms(req.Platforms)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	select {
+	case checkSubscriptionSlot <- struct{}{}:
+		defer func() { <-checkSubscriptionSlot }()
+	default:
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "已有订阅正在试检测，请稍后再试"})
+		return
+	}
+
+	start := time.Now()
+	data := []byte(req.Content)
+	if req.URL != "" {
+		data, err = proxyutils.GetDateFromSubs(c.Request.Context(), utils.WarpUrl(req.URL))
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("获取订阅失败: %v", err)})
+			return
+		}
+	}
+	proxies, err := proxyutils.ParseSubscription(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("解析订阅失败: %v", err)})
+		return
+	}
+
+	// 节点来源记为该订阅，方便在结果中区分
+	if req.URL != "" {
+		var tag string
+		if d, err := u.Parse(req.URL); err == nil {
+			tag = d.Fragment
+		}
+		for _, proxy := range proxies {
+			proxy["sub_url"] = req.URL
+			proxy["sub_tag"] = tag
+		}
+	}
+
+	results, traffic := check.CheckProxies(c.Request.Context(), proxies, platforms)
+
+	failures := utils.NewFailureStats()
+	nodes := make([]save.NodeReport, 0, len(results))
+	available := 0
+	for _, result := range results {
+		if result.Failure == "" {
+			available++
+		} else {
+			typ, _ := result.Proxy["type"].(string)
+			failures.Add(utils.SubscriptionName(result.SubURL, result.SubTag), typ, string(result.Failure))
+		}
+		nodes = append(nodes, save.NewNodeReport(result))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"fetched":   len(proxies),
+		"deduped":   len(results),
+		"available": available,
+		"duration":  time.Since(start).Seconds(),
+		"traffic":   traffic,
+		"failures":  failures,
+		"nodes":     nodes,
+	})
+}
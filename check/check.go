@@ -2,9 +2,11 @@ package check
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"math"
 	"net"
 	"net/http"
@@ -16,9 +18,11 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/beck-8/subs-check/check/platform"
 	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/events"
+	"github.com/beck-8/subs-check/metrics"
 	proxyutils "github.com/beck-8/subs-check/proxy"
+	"github.com/beck-8/subs-check/utils"
 	"github.com/juju/ratelimit"
 	"github.com/metacubex/mihomo/adapter"
 	"github.com/metacubex/mihomo/constant"
@@ -39,17 +43,33 @@ type Result struct {
 	IP         string
 	IPRisk     string
 	Country    string
+	Speed      int           // 测速结果(KB/s)，未开启测速时为0
+	Latency    int           // 连通性检测延迟(ms)
+	SubURL     string        // 节点来源订阅链接
+	SubTag     string        // 节点来源订阅备注
+	Tags       []string      // 添加到节点名称中的标记
+	Failure    FailureReason // 检测失败的原因，为空表示节点可用
 }
 
 // ProxyChecker 处理代理检测的主要结构体
 type ProxyChecker struct {
-	results     []Result
-	proxyCount  int
-	threadCount int
-	progress    int32
-	available   int32
-	resultChan  chan Result
-	tasks       chan map[string]any
+	results    []Result
+	proxyCount int
+	progress   int32
+	available  int32
+	resultChan chan Result
+	tasks      chan *stageTask
+	failures   *utils.FailureStats
+	failed     []Result
+	skipped    []Result // 检测被取消而中断的节点，不保存到断点
+	dispatched int
+	platforms  []string          // 需要检测的平台，为空时不检测流媒体
+	traffic    *atomic.Uint64    // 消耗流量的统计位置
+	bucket     *ratelimit.Bucket // 测速限速
+	standalone bool              // 单独检测节点，不重命名节点
+	checkpoint *checkpoint       // 检测断点，为空时不保存进度
+	deadline   time.Time         // 达到后停止派发任务，为零值时不限制
+	partial    bool              // 达到 max-run-duration 或被取消，有节点没有完成检测
 }
 
 var Progress atomic.Uint32
@@ -57,86 +77,292 @@ var Available atomic.Uint32
 var ProxyCount atomic.Uint32
 var TotalBytes atomic.Uint64
 
-var ForceClose atomic.Bool
-
 var Bucket *ratelimit.Bucket
 
+// runGracePeriod 达到 max-run-duration 停止派发后，等待正在检测的节点完成的时间
+const runGracePeriod = 30 * time.Second
+
+// RunInfo 单次检测的运行信息，用于生成运行报告
+type RunInfo struct {
+	Started  time.Time
+	Finished time.Time
+	Traffic  uint64              // 消耗的流量(字节)
+	Fetched  int                 // 获取的节点数量
+	Deduped  int                 // 去重后的节点数量
+	Results  []Result            // 可用的节点
+	Failed   []Result            // 失败和未检测的节点
+	Failures *utils.FailureStats // 失败原因统计，不包含未检测的节点
+	Partial  bool                // 达到 max-run-duration 或被取消，只包含部分节点的结果
+}
+
+var lastRun atomic.Pointer[RunInfo]
+
+// LastRun 返回上一次检测的运行信息，还没有完成过检测时返回 nil
+func LastRun() *RunInfo {
+	return lastRun.Load()
+}
+
+// LastFailures 返回上一次检测的失败原因统计，还没有完成过检测时返回 nil
+func LastFailures() *utils.FailureStats {
+	if run := lastRun.Load(); run != nil {
+		return run.Failures
+	}
+	return nil
+}
+
 // NewProxyChecker 创建新的检测器实例
 func NewProxyChecker(proxyCount int) *ProxyChecker {
-	threadCount := config.GlobalConfig.Concurrent
-	if proxyCount < threadCount {
-		threadCount = proxyCount
+	ProxyCount.Store(uint32(proxyCount))
+	return &ProxyChecker{
+		results:    make([]Result, 0),
+		proxyCount: proxyCount,
+		resultChan: make(chan Result),
+		tasks:      make(chan *stageTask, 1),
+		failures:   utils.NewFailureStats(),
+		platforms:  enabledPlatforms(),
+		traffic:    &TotalBytes,
 	}
+}
 
-	ProxyCount.Store(uint32(proxyCount))
+// enabledPlatforms 配置中需要检测的平台，未开启流媒体检测时为空
+func enabledPlatforms() []string {
+	if !config.GlobalConfig.MediaCheck {
+		return nil
+	}
+	return config.GlobalConfig.Platforms
+}
+
+// newBucket 按 total-speed-limit 创建测速限速
+func newBucket() *ratelimit.Bucket {
+	if config.GlobalConfig.TotalSpeedLimit != 0 {
+		return ratelimit.NewBucketWithRate(float64(config.GlobalConfig.TotalSpeedLimit*1024*1024), int64(config.GlobalConfig.TotalSpeedLimit*1024*1024/10))
+	}
+	return ratelimit.NewBucketWithRate(float64(math.MaxInt64), int64(math.MaxInt64))
+}
+
+// newStandaloneChecker 创建单独检测使用的检测器
+// 使用独立的限速和流量统计，不重命名节点，也不更新全局计数、事件和指标，不影响正在进行的检测
+func newStandaloneChecker(platforms []string) (*ProxyChecker, *atomic.Uint64) {
+	traffic := &atomic.Uint64{}
 	return &ProxyChecker{
-		results:     make([]Result, 0),
-		proxyCount:  proxyCount,
-		threadCount: threadCount,
-		resultChan:  make(chan Result),
-		tasks:       make(chan map[string]any, 1),
+		platforms:  platforms,
+		traffic:    traffic,
+		bucket:     newBucket(),
+		standalone: true,
+	}, traffic
+}
+
+// CheckNode 单独检测一个节点并返回检测结果和消耗的流量，platforms 为需要检测的平台
+func CheckNode(ctx context.Context, proxy map[string]any, platforms []string) (Result, uint64) {
+	pc, traffic := newStandaloneChecker(platforms)
+	res := pc.checkProxy(ctx, maps.Clone(proxy))
+	return *res, traffic.Load()
+}
+
+// CheckProxies 单独检测一组节点，去重后按 concurrent 并发检测，返回全部节点的检测结果和消耗的流量
+func CheckProxies(ctx context.Context, proxies []map[string]any, platforms []string) ([]Result, uint64) {
+	pc, traffic := newStandaloneChecker(platforms)
+	proxies = proxyutils.DeduplicateProxies(proxies)
+	results := make([]Result, len(proxies))
+
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, max(1, min(config.GlobalConfig.Concurrent, len(proxies))))
+	for i, proxy := range proxies {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(i int, proxy map[string]any) {
+			defer wg.Done()
+			defer func() { <-limit }()
+			results[i] = *pc.checkProxy(ctx, maps.Clone(proxy))
+		}(i, proxy)
 	}
+	wg.Wait()
+	return results, traffic.Load()
 }
 
 // Check 执行代理检测的主函数
-func Check() ([]Result, error) {
+// ctx 取消时停止获取订阅和派发任务，正在进行的检测也会立即中断
+// 取消后返回已完成的检测结果和包装了 ctx 错误的 error
+func Check(ctx context.Context) ([]Result, error) {
+	start := time.Now()
+	// 从断点继续时 start 为原来的开始时间，运行时长限制从本次开始计算
+	deadline := runDeadline(start)
 	proxyutils.ResetRenameCounter()
-	ForceClose.Store(false)
 
 	ProxyCount.Store(0)
 	Available.Store(0)
 	Progress.Store(0)
 
 	TotalBytes.Store(0)
+	events.Publish(events.RunStarted, nil)
 
-	// 之前好的节点前置
+	// 有上一次中断的检测时从断点继续，不重新获取订阅
+	cp := resumeCheckpoint()
 	var proxies []map[string]any
-	if config.GlobalConfig.KeepSuccessProxies {
-		slog.Info(fmt.Sprintf("添加之前测试成功的节点，数量: %d", len(config.GlobalProxies)))
-		proxies = append(proxies, config.GlobalProxies...)
-	}
-	tmp, err := proxyutils.GetProxies()
-	if err != nil {
-		return nil, fmt.Errorf("获取节点失败: %w", err)
+	var fetched int
+	if cp != nil {
+		start = cp.Started
+		fetched = cp.Fetched
+		proxies = cp.Proxies
+		TotalBytes.Store(cp.Traffic)
+		slog.Info(fmt.Sprintf("继续上一次中断的检测，已完成: %d/%d", len(cp.Done), len(proxies)))
+	} else {
+		// 之前好的节点前置
+		if config.GlobalConfig.KeepSuccessProxies {
+			slog.Info(fmt.Sprintf("添加之前测试成功的节点，数量: %d", len(config.GlobalProxies)))
+			proxies = append(proxies, config.GlobalProxies...)
+		}
+		tmp, err := proxyutils.GetProxies(ctx)
+		if err != nil {
+			err = fmt.Errorf("获取节点失败: %w", err)
+			events.Publish(events.RunFinished, events.RunFinishedData{Duration: time.Since(start).Seconds(), Error: err.Error()})
+			return nil, err
+		}
+		proxies = append(proxies, tmp...)
+		fetched = len(proxies)
+		slog.Info(fmt.Sprintf("获取节点数量: %d", fetched))
+
+		proxies = proxyutils.DeduplicateProxies(proxies)
+		slog.Info(fmt.Sprintf("去重后节点数量: %d", len(proxies)))
+
+		if config.GlobalConfig.CheckpointInterval > 0 {
+			var err error
+			if cp, err = newCheckpoint(checkpointDir(), start, fetched, proxies); err != nil {
+				slog.Warn(fmt.Sprintf("创建检测断点失败，本次检测不保存进度: %v", err))
+			}
+		}
 	}
-	proxies = append(proxies, tmp...)
-	slog.Info(fmt.Sprintf("获取节点数量: %d", len(proxies)))
+	metrics.NodesFetched.Set(float64(fetched))
+	metrics.NodesDeduped.Set(float64(len(proxies)))
 
 	// 重置全局节点
 	config.GlobalProxies = make([]map[string]any, 0)
 
-	proxies = proxyutils.DeduplicateProxies(proxies)
-	slog.Info(fmt.Sprintf("去重后节点数量: %d", len(proxies)))
-
 	checker := NewProxyChecker(len(proxies))
-	return checker.run(proxies)
-}
+	checker.resume(cp)
+	checker.deadline = deadline
+	results, err := checker.run(ctx, proxies)
+	// 检测结束(包括被取消)后不再需要断点，只有进程中途退出时才保留
+	if cp != nil {
+		cp.remove()
+	}
+	lastRun.Store(&RunInfo{
+		Started:  start,
+		Finished: time.Now(),
+		Traffic:  TotalBytes.Load(),
+		Fetched:  fetched,
+		Deduped:  len(proxies),
+		Results:  results,
+		Failed:   checker.failed,
+		Failures: checker.failures,
+		Partial:  checker.partial,
+	})
 
-// Run 运行检测流程
-func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
-	if config.GlobalConfig.TotalSpeedLimit != 0 {
-		Bucket = ratelimit.NewBucketWithRate(float64(config.GlobalConfig.TotalSpeedLimit*1024*1024), int64(config.GlobalConfig.TotalSpeedLimit*1024*1024/10))
+	metrics.Runs.Inc()
+	metrics.RunDuration.Set(time.Since(start).Seconds())
+	metrics.RunLastFinished.Set(float64(time.Now().Unix()))
+	metrics.TrafficBytes.Add(float64(TotalBytes.Load()))
+	if checker.partial {
+		metrics.RunPartial.Set(1)
 	} else {
-		Bucket = ratelimit.NewBucketWithRate(float64(math.MaxInt64), int64(math.MaxInt64))
+		metrics.RunPartial.Set(0)
+	}
+
+	finished := events.RunFinishedData{
+		Total:     len(proxies),
+		Available: len(results),
+		Duration:  time.Since(start).Seconds(),
+		Traffic:   TotalBytes.Load(),
+		Partial:   checker.partial,
+	}
+	if err != nil {
+		finished.Error = err.Error()
+	}
+	events.Publish(events.RunFinished, finished)
+	return results, err
+}
+
+// runDeadline 按 max-run-duration 计算停止派发任务的时间，未设置时返回零值
+func runDeadline(start time.Time) time.Time {
+	if config.GlobalConfig.MaxRunDuration <= 0 {
+		return time.Time{}
+	}
+	return start.Add(time.Duration(config.GlobalConfig.MaxRunDuration) * time.Minute)
+}
+
+// resumeCheckpoint 读取上一次中断的检测的断点，没有断点或未开启时返回 nil
+func resumeCheckpoint() *checkpoint {
+	if config.GlobalConfig.CheckpointInterval <= 0 {
+		return nil
+	}
+	dir := checkpointDir()
+	cp, err := loadCheckpoint(dir)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("读取检测断点失败，重新开始检测: %v", err))
+		os.RemoveAll(dir)
+		return nil
+	}
+	return cp
+}
+
+// resume 从断点恢复已完成的结果、进度和重命名编号
+func (pc *ProxyChecker) resume(cp *checkpoint) {
+	pc.checkpoint = cp
+	if cp == nil {
+		return
+	}
+	pc.results = append(pc.results, cp.Results...)
+	pc.failed = append(pc.failed, cp.Failed...)
+	for _, result := range cp.Failed {
+		typ, _ := result.Proxy["type"].(string)
+		pc.failures.Add(utils.SubscriptionName(result.SubURL, result.SubTag), typ, string(result.Failure))
 	}
+	pc.progress = int32(len(cp.Done))
+	pc.available = int32(len(cp.Results))
+	Progress.Store(uint32(pc.progress))
+	Available.Store(uint32(pc.available))
+
+	if config.GlobalConfig.RenameNode {
+		counts := make(map[string]int)
+		for _, result := range cp.Results {
+			counts[result.Country]++
+		}
+		proxyutils.RestoreRenameCounter(counts)
+	}
+}
+
+// Run 运行检测流程
+func (pc *ProxyChecker) run(ctx context.Context, proxies []map[string]any) ([]Result, error) {
+	Bucket = newBucket()
+	pc.bucket = Bucket
 
 	slog.Info("开始检测节点")
-	slog.Info("当前参数", "timeout", config.GlobalConfig.Timeout, "concurrent", config.GlobalConfig.Concurrent, "enable-speedtest", config.GlobalConfig.SpeedTestUrl != "", "min-speed", config.GlobalConfig.MinSpeed, "download-timeout", config.GlobalConfig.DownloadTimeout, "download-mb", config.GlobalConfig.DownloadMB, "total-speed-limit", config.GlobalConfig.TotalSpeedLimit)
+	slog.Info("当前参数", "timeout", config.GlobalConfig.Timeout, "concurrent", config.GlobalConfig.Concurrent, "latency-concurrent", config.GlobalConfig.LatencyConcurrent, "speed-concurrent", config.GlobalConfig.SpeedConcurrent, "media-concurrent", config.GlobalConfig.MediaConcurrent, "enable-speedtest", config.GlobalConfig.SpeedTestUrl != "", "min-speed", config.GlobalConfig.MinSpeed, "download-timeout", config.GlobalConfig.DownloadTimeout, "download-mb", config.GlobalConfig.DownloadMB, "total-speed-limit", config.GlobalConfig.TotalSpeedLimit)
 
 	done := make(chan bool)
 	if config.GlobalConfig.PrintProgress {
 		go pc.showProgress(done)
 	}
-	var wg sync.WaitGroup
-	// 启动工作线程
-	for i := 0; i < pc.threadCount; i++ {
-		wg.Add(1)
-		go pc.worker(&wg)
+	// 达到 max-run-duration 时停止派发，再等待 runGracePeriod 后中断仍在检测的节点
+	dispatchCtx, checkCtx := ctx, ctx
+	if !pc.deadline.IsZero() {
+		var cancelDispatch, cancelCheck context.CancelFunc
+		dispatchCtx, cancelDispatch = context.WithDeadline(ctx, pc.deadline)
+		defer cancelDispatch()
+		checkCtx, cancelCheck = context.WithDeadline(ctx, pc.deadline.Add(runGracePeriod))
+		defer cancelCheck()
 	}
 
-	// 发送任务
-	go pc.distributeProxies(proxies)
-	slog.Debug(fmt.Sprintf("发送任务: %d", len(proxies)))
+	// 启动各阶段的工作线程
+	pc.startPipeline(checkCtx, pc.stages())
+
+	// 发送任务，从断点恢复时只发送没有完成的节点
+	pending := proxies
+	if pc.checkpoint != nil {
+		pending = pc.checkpoint.pending()
+	}
+	go pc.distributeProxies(dispatchCtx, pending)
+	slog.Debug(fmt.Sprintf("发送任务: %d", len(pending)))
 
 	// 收集结果 - 添加一个 WaitGroup 来等待结果收集完成
 	var collectWg sync.WaitGroup
@@ -146,10 +372,7 @@ func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
 		collectWg.Done()
 	}()
 
-	wg.Wait()
-	close(pc.resultChan)
-
-	// 等待结果收集完成
+	// 等待结果收集完成，最后一个阶段结束后 resultChan 会被关闭
 	collectWg.Wait()
 	// 等待进度条显示完成
 	time.Sleep(100 * time.Millisecond)
@@ -164,124 +387,75 @@ func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
 	slog.Info(fmt.Sprintf("可用节点数量: %d", len(pc.results)))
 	slog.Info(fmt.Sprintf("测试总消耗流量: %.3fGB", float64(TotalBytes.Load())/1024/1024/1024))
 
-	// 检查订阅成功率并发出警告
-	pc.checkSubscriptionSuccessRate(proxies)
-
-	return pc.results, nil
-}
-
-// worker 处理单个代理检测的工作线程
-func (pc *ProxyChecker) worker(wg *sync.WaitGroup) {
-	defer wg.Done()
-	for proxy := range pc.tasks {
-		if result := pc.checkProxy(proxy); result != nil {
-			pc.resultChan <- *result
-		}
-		pc.incrementProgress()
-	}
-}
-
-// checkProxy 检测单个代理
-func (pc *ProxyChecker) checkProxy(proxy map[string]any) *Result {
-	res := &Result{
-		Proxy: proxy,
+	// 达到时间限制或被取消时没有派发和被中断的节点都是未完成检测
+	pc.partial = dispatchCtx.Err() != nil && (pc.dispatched < len(pending) || len(pc.skipped) > 0)
+	if pc.partial && ctx.Err() == nil {
+		slog.Warn(fmt.Sprintf("达到最大运行时长 %d 分钟，发布已完成的检测结果", config.GlobalConfig.MaxRunDuration))
 	}
 
-	if os.Getenv("SUB_CHECK_SKIP") != "" {
-		// slog.Debug(fmt.Sprintf("跳过检测代理: %v", proxy["name"]))
-		return res
+	// 达到数量限制、时间限制或检测被取消时没有派发的节点记为未检测
+	pc.failed = append(pc.failed, pc.skipped...)
+	for _, proxy := range pending[pc.dispatched:] {
+		res := Result{Proxy: proxy, Failure: FailureSkipped}
+		res.SubURL, _ = proxy["sub_url"].(string)
+		res.SubTag, _ = proxy["sub_tag"].(string)
+		pc.failed = append(pc.failed, res)
 	}
 
-	httpClient := CreateClient(proxy)
-	if httpClient == nil {
-		slog.Debug(fmt.Sprintf("创建代理Client失败: %v", proxy["name"]))
-		return nil
-	}
-	defer httpClient.Close()
+	// 检查订阅成功率并发出警告
+	pc.checkSubscriptionSuccessRate(proxies)
 
-	cloudflare, err := platform.CheckCloudflare(httpClient.Client)
-	if err != nil || !cloudflare {
-		return nil
+	if err := ctx.Err(); err != nil {
+		return pc.results, fmt.Errorf("检测已取消: %w", err)
 	}
+	return pc.results, nil
+}
 
-	google, err := platform.CheckGoogle(httpClient.Client)
-	if err != nil || !google {
-		return nil
-	}
+// fail 记录失败原因
+func fail(res *Result, reason FailureReason) *Result {
+	res.Failure = reason
+	return res
+}
 
-	var speed int
-	if config.GlobalConfig.SpeedTestUrl != "" {
-		speed, _, err = platform.CheckSpeed(httpClient.Client, Bucket)
-		if err != nil || speed < config.GlobalConfig.MinSpeed {
-			return nil
-		}
+// failError 按错误记录失败原因，检测被取消导致的失败记为未检测
+func failError(ctx context.Context, res *Result, err error) *Result {
+	if ctx.Err() != nil {
+		return fail(res, FailureSkipped)
 	}
+	return fail(res, classifyError(err))
+}
 
-	if config.GlobalConfig.MediaCheck {
-		// 遍历需要检测的平台
-		for _, plat := range config.GlobalConfig.Platforms {
-			switch plat {
-			case "openai":
-				cookiesOK, clientOK := platform.CheckOpenAI(httpClient.Client)
-				if clientOK && cookiesOK {
-					res.Openai = true
-				} else if cookiesOK || clientOK {
-					res.OpenaiWeb = true
-				}
-			case "youtube":
-				if region, _ := platform.CheckYoutube(httpClient.Client); region != "" {
-					res.Youtube = region
-				}
-			case "netflix":
-				if ok, _ := platform.CheckNetflix(httpClient.Client); ok {
-					res.Netflix = true
-				}
-			case "disney":
-				if ok, _ := platform.CheckDisney(httpClient.Client); ok {
-					res.Disney = true
-				}
-			case "gemini":
-				if ok, _ := platform.CheckGemini(httpClient.Client); ok {
-					res.Gemini = true
-				}
-			case "iprisk":
-				country, ip := proxyutils.GetProxyCountry(httpClient.Client)
-				if ip == "" {
-					break
-				}
-				res.IP = ip
-				res.Country = country
-				risk, err := platform.CheckIPRisk(httpClient.Client, ip)
-				if err == nil {
-					res.IPRisk = risk
-				} else {
-					// 失败的可能性高，所以放上日志
-					slog.Debug(fmt.Sprintf("查询IP风险失败: %v", err))
-				}
-			case "tiktok":
-				if region, _ := platform.CheckTikTok(httpClient.Client); region != "" {
-					res.TikTok = region
-				}
-			}
-		}
-	}
-	// 更新代理名称
-	pc.updateProxyName(res, httpClient, speed)
-	pc.incrementAvailable()
-	return res
+// publishNode 广播单个节点的检测结果
+func publishNode(res *Result) {
+	name, _ := res.Proxy["name"].(string)
+	typ, _ := res.Proxy["type"].(string)
+	events.Publish(events.NodeChecked, events.NodeCheckedData{
+		Name:         name,
+		Type:         typ,
+		Subscription: utils.SubscriptionName(res.SubURL, res.SubTag),
+		Passed:       res.Failure == "",
+		Reason:       string(res.Failure),
+		Latency:      res.Latency,
+		Speed:        res.Speed,
+		Tags:         res.Tags,
+	})
 }
 
 // updateProxyName 更新代理名称
-func (pc *ProxyChecker) updateProxyName(res *Result, httpClient *ProxyClient, speed int) {
-	// 以节点IP查询位置重命名节点
-	if config.GlobalConfig.RenameNode {
-		if res.Country != "" {
-			res.Proxy["name"] = config.GlobalConfig.NodePrefix + proxyutils.Rename(res.Country)
-		} else {
-			country, _ := proxyutils.GetProxyCountry(httpClient.Client)
-			res.Proxy["name"] = config.GlobalConfig.NodePrefix + proxyutils.Rename(country)
+func (pc *ProxyChecker) updateProxyName(ctx context.Context, res *Result, httpClient *ProxyClient, speed int) {
+	// 始终查询节点位置，统计数据、国家代理组和输出配置的 country 筛选都依赖国家
+	if res.Country == "" {
+		country, ip := proxyutils.GetProxyCountry(ctx, httpClient.Client)
+		res.Country = country
+		if res.IP == "" {
+			res.IP = ip
 		}
 	}
+	// 以节点位置重命名节点
+	// 单独检测时不重命名，避免占用正在进行的检测的节点编号
+	if config.GlobalConfig.RenameNode && !pc.standalone {
+		res.Proxy["name"] = config.GlobalConfig.NodePrefix + proxyutils.Rename(res.Country)
+	}
 
 	name := res.Proxy["name"].(string)
 	name = strings.TrimSpace(name)
@@ -299,13 +473,13 @@ func (pc *ProxyChecker) updateProxyName(res *Result, httpClient *ProxyClient, sp
 		tags = append(tags, speedStr)
 	}
 
-	if config.GlobalConfig.MediaCheck {
+	if len(pc.platforms) > 0 {
 		// 移除已有的标记（IPRisk和平台标记）
 		name = regexp.MustCompile(`\s*\|(?:NF|D\+|GPT⁺|GPT|GM|YT-[^|]+|TK-[^|]+|\d+%)`).ReplaceAllString(name, "")
 	}
 
 	// 按用户输入顺序定义
-	for _, plat := range config.GlobalConfig.Platforms {
+	for _, plat := range pc.platforms {
 		switch plat {
 		case "openai":
 			if res.Openai {
@@ -350,7 +524,7 @@ func (pc *ProxyChecker) updateProxyName(res *Result, httpClient *ProxyClient, sp
 	}
 
 	res.Proxy["name"] = name
-
+	res.Tags = tags
 }
 
 // showProgress 显示进度条
@@ -394,29 +568,59 @@ func (pc *ProxyChecker) incrementAvailable() {
 }
 
 // distributeProxies 分发代理任务
-func (pc *ProxyChecker) distributeProxies(proxies []map[string]any) {
+func (pc *ProxyChecker) distributeProxies(ctx context.Context, proxies []map[string]any) {
+	defer close(pc.tasks)
 	for _, proxy := range proxies {
 		if config.GlobalConfig.SuccessLimit > 0 && atomic.LoadInt32(&pc.available) >= config.GlobalConfig.SuccessLimit {
 			break
 		}
-		if ForceClose.Load() {
-			slog.Warn("收到强制关闭信号，停止派发任务")
-			break
+		if ctx.Err() != nil {
+			logDispatchStopped(ctx)
+			return
+		}
+		select {
+		case pc.tasks <- newStageTask(proxy):
+			pc.dispatched++
+		case <-ctx.Done():
+			logDispatchStopped(ctx)
+			return
 		}
-		pc.tasks <- proxy
 	}
 	// // 发送任务结束，进行一次内存回收
 	// for i := range proxies {
 	// 	proxies[i] = nil // 移除 map 引用
 	// }
 	// proxies = nil // 移除切片引用
-	close(pc.tasks)
 }
 
-// collectResults 收集检测结果
+// logDispatchStopped 记录停止派发任务的原因
+func logDispatchStopped(ctx context.Context) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		slog.Warn("达到最大运行时长，停止派发任务")
+		return
+	}
+	slog.Warn("检测已取消，停止派发任务")
+}
+
+// collectResults 收集检测结果，失败的节点单独保存并统计失败原因
 func (pc *ProxyChecker) collectResults() {
 	for result := range pc.resultChan {
-		pc.results = append(pc.results, result)
+		// 检测被取消而中断的节点和没有派发的节点一样，不计入失败原因统计
+		if result.Failure == FailureSkipped {
+			pc.skipped = append(pc.skipped, result)
+			continue
+		}
+		if result.Failure != "" {
+			typ, _ := result.Proxy["type"].(string)
+			pc.failures.Add(utils.SubscriptionName(result.SubURL, result.SubTag), typ, string(result.Failure))
+			pc.failed = append(pc.failed, result)
+		} else {
+			pc.results = append(pc.results, result)
+		}
+		if pc.checkpoint != nil {
+			pc.checkpoint.complete(result)
+			pc.checkpoint.saveIfDue(pc.results, pc.failed)
+		}
 	}
 }
 
@@ -476,9 +680,16 @@ type ProxyClient struct {
 	*http.Client
 	proxy     constant.Proxy
 	Transport *StatsTransport
+	traffic   *atomic.Uint64
 }
 
-func CreateClient(mapping map[string]any) *ProxyClient {
+func CreateClient(ctx context.Context, mapping map[string]any) *ProxyClient {
+	return createClient(ctx, mapping, &TotalBytes)
+}
+
+// createClient 创建代理Client，关闭时将消耗的流量累加到 traffic
+// ctx 取消后不再建立新的连接，已有的连接由请求自身的 ctx 中断
+func createClient(ctx context.Context, mapping map[string]any, traffic *atomic.Uint64) *ProxyClient {
 	proxy, err := adapter.ParseProxy(mapping)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("底层mihomo创建代理Client失败: %v", err))
@@ -486,7 +697,10 @@ func CreateClient(mapping map[string]any) *ProxyClient {
 	}
 
 	baseTransport := &http.Transport{
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		DialContext: func(dialCtx context.Context, network, addr string) (net.Conn, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			host, port, err := net.SplitHostPort(addr)
 			if err != nil {
 				return nil, err
@@ -495,7 +709,7 @@ func CreateClient(mapping map[string]any) *ProxyClient {
 			if port, err := strconv.ParseUint(port, 10, 16); err == nil {
 				u16Port = uint16(port)
 			}
-			return proxy.DialContext(ctx, &constant.Metadata{
+			return proxy.DialContext(dialCtx, &constant.Metadata{
 				Host:    host,
 				DstPort: u16Port,
 			})
@@ -513,6 +727,7 @@ func CreateClient(mapping map[string]any) *ProxyClient {
 		},
 		proxy:     proxy,
 		Transport: statsTransport,
+		traffic:   traffic,
 	}
 }
 
@@ -532,7 +747,7 @@ func (pc *ProxyClient) Close() {
 	pc.Client = nil
 
 	if pc.Transport != nil {
-		TotalBytes.Add(atomic.LoadUint64(&pc.Transport.BytesRead))
+		pc.traffic.Add(atomic.LoadUint64(&pc.Transport.BytesRead))
 		// 手动关闭transport，此处非常重要
 		// 如果没有此处，链接不会释放，goroutine会一直占用，内存会溢出
 		if pc.Transport.Base != nil {
@@ -0,0 +1,96 @@
+package check
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+func TestCheckProxiesStandalone(t *testing.T) {
+	t.Setenv("SUB_CHECK_SKIP", "1")
+	Available.Store(7)
+	Progress.Store(9)
+	t.Cleanup(func() {
+		Available.Store(0)
+		Progress.Store(0)
+	})
+
+	proxies := []map[string]any{
+		{"name": "a", "type": "ss", "server": "1.1.1.1", "port": 443, "password": "x"},
+		{"name": "b", "type": "ss", "server": "1.1.1.1", "port": 443, "password": "x"},
+		{"name": "c", "type": "ss", "server": "2.2.2.2", "port": 443, "password": "x"},
+	}
+	results, traffic := CheckProxies(context.Background(), proxies, nil)
+
+	if len(results) != 2 {
+		t.Fatalf("CheckProxies() 返回 %d 个结果, want 2", len(results))
+	}
+	if results[0].Proxy["name"] != "a" || results[1].Proxy["name"] != "c" {
+		t.Errorf("结果顺序与输入不一致: %v, %v", results[0].Proxy["name"], results[1].Proxy["name"])
+	}
+	if traffic != 0 {
+		t.Errorf("traffic = %d, want 0", traffic)
+	}
+	if Available.Load() != 7 || Progress.Load() != 9 {
+		t.Errorf("单独检测修改了全局计数: available=%d progress=%d", Available.Load(), Progress.Load())
+	}
+}
+
+func TestCheckNodeCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	proxy := map[string]any{"name": "a", "type": "ss", "server": "127.0.0.1", "port": 1, "cipher": "aes-128-gcm", "password": "x"}
+	result, _ := CheckNode(ctx, proxy, nil)
+	if result.Failure != FailureSkipped {
+		t.Errorf("取消后 Failure = %q, want %q", result.Failure, FailureSkipped)
+	}
+}
+
+func TestRunDeadlinePartial(t *testing.T) {
+	t.Setenv("SUB_CHECK_SKIP", "1")
+	concurrent := config.GlobalConfig.Concurrent
+	config.GlobalConfig.Concurrent = 2
+	t.Cleanup(func() {
+		config.GlobalConfig.Concurrent = concurrent
+		ProxyCount.Store(0)
+		Available.Store(0)
+		Progress.Store(0)
+	})
+
+	proxies := []map[string]any{
+		{"name": "a", "type": "ss", "server": "1.1.1.1", "port": 443, "password": "x"},
+		{"name": "b", "type": "ss", "server": "2.2.2.2", "port": 443, "password": "x"},
+	}
+	tests := []struct {
+		name      string
+		deadline  time.Time
+		partial   bool
+		available int
+	}{
+		{"不限制", time.Time{}, false, 2},
+		{"未到期", time.Now().Add(time.Hour), false, 2},
+		{"已到期", time.Now().Add(-time.Second), true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := NewProxyChecker(len(proxies))
+			pc.deadline = tt.deadline
+			results, err := pc.run(context.Background(), proxies)
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if pc.partial != tt.partial {
+				t.Errorf("partial = %v, want %v", pc.partial, tt.partial)
+			}
+			if len(results) != tt.available {
+				t.Errorf("可用节点 = %d, want %d", len(results), tt.available)
+			}
+			if len(results)+len(pc.failed) != len(proxies) {
+				t.Errorf("结果总数 = %d, want %d", len(results)+len(pc.failed), len(proxies))
+			}
+		})
+	}
+}
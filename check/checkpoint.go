@@ -0,0 +1,163 @@
+package check
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+	proxyutils "github.com/beck-8/subs-check/proxy"
+	"github.com/beck-8/subs-check/utils"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	checkpointTasksFile = "tasks.yaml"
+	checkpointStateFile = "state.yaml"
+)
+
+// checkpointTasks 去重后的任务列表，检测开始前写入一次
+type checkpointTasks struct {
+	Started time.Time        `yaml:"started"`
+	Fetched int              `yaml:"fetched"`
+	Proxies []map[string]any `yaml:"proxies"`
+}
+
+// checkpointState 检测进度，检测过程中定期写入
+type checkpointState struct {
+	Updated time.Time `yaml:"updated"`
+	Traffic uint64    `yaml:"traffic"`
+	Done    []string  `yaml:"done"`    // 已完成检测的节点 ID
+	Results []Result  `yaml:"results"` // 已完成检测的可用节点
+	Failed  []Result  `yaml:"failed"`  // 已完成检测的失败节点，不包含未检测的节点
+}
+
+// checkpoint 检测的断点，程序崩溃或重启后从断点继续检测
+type checkpoint struct {
+	dir   string
+	saved time.Time
+	checkpointTasks
+	checkpointState
+}
+
+// checkpointDir 断点保存目录
+func checkpointDir() string {
+	return filepath.Join(utils.GetConfigDir(), "cache", "checkpoint")
+}
+
+// newCheckpoint 为新的检测创建断点并写入任务列表
+func newCheckpoint(dir string, started time.Time, fetched int, proxies []map[string]any) (*checkpoint, error) {
+	cp := &checkpoint{
+		dir:             dir,
+		saved:           time.Now(),
+		checkpointTasks: checkpointTasks{Started: started, Fetched: fetched, Proxies: proxies},
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建断点目录失败: %w", err)
+	}
+	// 先删除旧的进度，避免任务列表与进度不匹配
+	if err := os.Remove(filepath.Join(dir, checkpointStateFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("删除旧的检测进度失败: %w", err)
+	}
+	if err := writeYAML(filepath.Join(dir, checkpointTasksFile), &cp.checkpointTasks); err != nil {
+		return nil, fmt.Errorf("保存任务列表失败: %w", err)
+	}
+	return cp, nil
+}
+
+// loadCheckpoint 读取上一次中断的检测的断点，没有断点时返回 nil
+func loadCheckpoint(dir string) (*checkpoint, error) {
+	cp := &checkpoint{dir: dir, saved: time.Now()}
+	if err := readYAML(filepath.Join(dir, checkpointTasksFile), &cp.checkpointTasks); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取任务列表失败: %w", err)
+	}
+	// 还没有保存过进度时从头检测任务列表
+	if err := readYAML(filepath.Join(dir, checkpointStateFile), &cp.checkpointState); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("读取检测进度失败: %w", err)
+	}
+	return cp, nil
+}
+
+// pending 返回还没有完成检测的节点
+func (cp *checkpoint) pending() []map[string]any {
+	done := make(map[string]struct{}, len(cp.Done))
+	for _, id := range cp.Done {
+		done[id] = struct{}{}
+	}
+	pending := make([]map[string]any, 0, len(cp.Proxies))
+	for _, proxy := range cp.Proxies {
+		if _, ok := done[proxyutils.ProxyID(proxy)]; !ok {
+			pending = append(pending, proxy)
+		}
+	}
+	return pending
+}
+
+// complete 记录一个完成检测的节点
+func (cp *checkpoint) complete(res Result) {
+	cp.Done = append(cp.Done, proxyutils.ProxyID(res.Proxy))
+}
+
+// saveIfDue 距离上一次保存超过 checkpoint-interval 时保存进度
+func (cp *checkpoint) saveIfDue(results, failed []Result) {
+	interval := time.Duration(config.GlobalConfig.CheckpointInterval) * time.Second
+	if time.Since(cp.saved) < interval {
+		return
+	}
+	cp.save(results, failed)
+}
+
+// save 保存检测进度，失败时只记录日志，不影响检测
+func (cp *checkpoint) save(results, failed []Result) {
+	cp.saved = time.Now()
+	cp.Updated = cp.saved
+	cp.Traffic = TotalBytes.Load()
+	cp.Results = results
+	cp.Failed = failed
+	if err := writeYAML(filepath.Join(cp.dir, checkpointStateFile), &cp.checkpointState); err != nil {
+		slog.Warn(fmt.Sprintf("保存检测进度失败: %v", err))
+	}
+}
+
+// remove 检测结束后删除断点
+func (cp *checkpoint) remove() {
+	if err := os.RemoveAll(cp.dir); err != nil {
+		slog.Warn(fmt.Sprintf("删除检测断点失败: %v", err))
+	}
+}
+
+// HasCheckpoint 是否存在上一次中断的检测，存在时启动后应立即检测以继续上一次的进度
+func HasCheckpoint() bool {
+	if config.GlobalConfig.CheckpointInterval <= 0 {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(checkpointDir(), checkpointTasksFile))
+	return err == nil
+}
+
+// writeYAML 先写入临时文件再重命名，避免进程中途退出留下不完整的文件
+func writeYAML(path string, v any) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func readYAML(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, v)
+}
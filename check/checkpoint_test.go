@@ -0,0 +1,57 @@
+package check
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "checkpoint")
+
+	cp, err := loadCheckpoint(dir)
+	if err != nil || cp != nil {
+		t.Fatalf("没有断点时 loadCheckpoint() = %v, %v, want nil, nil", cp, err)
+	}
+
+	proxies := []map[string]any{
+		{"name": "a", "type": "ss", "server": "1.1.1.1", "port": 443, "password": "x"},
+		{"name": "b", "type": "ss", "server": "2.2.2.2", "port": 443, "password": "x"},
+		{"name": "c", "type": "ss", "server": "3.3.3.3", "port": 443, "password": "x"},
+	}
+	started := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	cp, err = newCheckpoint(dir, started, 5, proxies)
+	if err != nil {
+		t.Fatalf("newCheckpoint() error = %v", err)
+	}
+
+	passed := Result{Proxy: proxies[0], Country: "US", Speed: 1024}
+	failed := Result{Proxy: proxies[2], Failure: FailureTimeout}
+	cp.complete(passed)
+	cp.complete(failed)
+	cp.save([]Result{passed}, []Result{failed})
+
+	cp, err = loadCheckpoint(dir)
+	if err != nil || cp == nil {
+		t.Fatalf("loadCheckpoint() = %v, %v", cp, err)
+	}
+	if !cp.Started.Equal(started) || cp.Fetched != 5 || len(cp.Proxies) != 3 {
+		t.Errorf("任务列表不一致: started=%v fetched=%d proxies=%d", cp.Started, cp.Fetched, len(cp.Proxies))
+	}
+	if len(cp.Results) != 1 || cp.Results[0].Country != "US" || cp.Results[0].Speed != 1024 {
+		t.Errorf("Results = %+v", cp.Results)
+	}
+	if len(cp.Failed) != 1 || cp.Failed[0].Failure != FailureTimeout {
+		t.Errorf("Failed = %+v", cp.Failed)
+	}
+
+	pending := cp.pending()
+	if len(pending) != 1 || pending[0]["name"] != "b" {
+		t.Errorf("pending() = %v, want 只有 b", pending)
+	}
+
+	cp.remove()
+	if cp, _ := loadCheckpoint(dir); cp != nil {
+		t.Errorf("remove() 后仍然可以读取断点")
+	}
+}
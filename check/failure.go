@@ -0,0 +1,62 @@
+package check
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"strings"
+)
+
+// FailureReason 节点检测失败的原因
+type FailureReason string
+
+const (
+	FailureParse      FailureReason = "parse-error"      // 节点配置无法解析
+	FailureTimeout    FailureReason = "dial-timeout"     // 连接或请求超时
+	FailureTLS        FailureReason = "tls-error"        // TLS 握手或证书错误
+	FailureAuth       FailureReason = "auth-rejected"    // 代理服务器拒绝认证
+	FailureBlocked    FailureReason = "http-blocked"     // 请求完成但返回了非预期的状态码
+	FailureTooSlow    FailureReason = "too-slow"         // 测速结果低于 min-speed
+	FailureConnection FailureReason = "connection-error" // 其他连接错误
+	FailureSkipped    FailureReason = "skipped"          // 达到数量限制或强制关闭，没有检测
+)
+
+// classifyError 根据检测请求的错误判断失败原因，err 为 nil 表示请求完成但结果不符合预期
+func classifyError(err error) FailureReason {
+	if err == nil {
+		return FailureBlocked
+	}
+
+	var (
+		certErr      *tls.CertificateVerificationError
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+	if errors.As(err, &certErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return FailureTLS
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return FailureTimeout
+	}
+
+	// mihomo 的协议错误大多没有类型，只能根据错误信息判断
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "tls"), strings.Contains(msg, "x509"), strings.Contains(msg, "certificate"):
+		return FailureTLS
+	case strings.Contains(msg, "auth"), strings.Contains(msg, "407"), strings.Contains(msg, "password"),
+		strings.Contains(msg, "invalid user"):
+		return FailureAuth
+	}
+	return FailureConnection
+}
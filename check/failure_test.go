@@ -0,0 +1,39 @@
+package check
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want FailureReason
+	}{
+		{"nil", nil, FailureBlocked},
+		{"deadline", fmt.Errorf("dial: %w", context.DeadlineExceeded), FailureTimeout},
+		{"net timeout", &url.Error{Op: "Get", URL: "https://gstatic.com/generate_204", Err: timeoutError{}}, FailureTimeout},
+		{"unknown authority", &url.Error{Op: "Get", URL: "https://gstatic.com", Err: x509.UnknownAuthorityError{}}, FailureTLS},
+		{"tls message", errors.New("remote error: tls: handshake failure"), FailureTLS},
+		{"proxy auth", errors.New("407 Proxy Authentication Required"), FailureAuth},
+		{"refused", errors.New("connect: connection refused"), FailureConnection},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
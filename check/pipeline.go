@@ -0,0 +1,265 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/beck-8/subs-check/check/platform"
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/metrics"
+	proxyutils "github.com/beck-8/subs-check/proxy"
+)
+
+// latencySamples 延迟取样次数，包括连通性检测的那一次，取最小值作为节点延迟
+const latencySamples = 3
+
+// stageTask 在各阶段之间传递的检测任务，client 在整个流水线中复用，检测结束时关闭
+type stageTask struct {
+	res    *Result
+	client *ProxyClient
+}
+
+func newStageTask(proxy map[string]any) *stageTask {
+	res := &Result{Proxy: proxy}
+	res.SubURL, _ = proxy["sub_url"].(string)
+	res.SubTag, _ = proxy["sub_tag"].(string)
+	return &stageTask{res: res}
+}
+
+// close 关闭代理Client，累加消耗的流量
+func (t *stageTask) close() {
+	if t.client != nil {
+		t.client.Close()
+		t.client = nil
+	}
+}
+
+// stage 检测流水线中的一个阶段
+// check 返回 true 表示进入下一阶段，返回 false 表示检测结束，失败时结果中已记录失败原因
+type stage struct {
+	name    string
+	workers int
+	check   func(ctx context.Context, t *stageTask) bool
+}
+
+// stages 按顺序返回检测阶段：连通性、延迟、测速和流媒体
+// 失效的节点在并发最高的连通性阶段就被淘汰，不占用测速和流媒体检测的线程
+func (pc *ProxyChecker) stages() []stage {
+	stages := []stage{
+		{"alive", pc.stageWorkers(config.GlobalConfig.Concurrent), pc.checkAlive},
+		{"latency", pc.stageWorkers(config.GlobalConfig.LatencyConcurrent), pc.checkLatency},
+	}
+	if config.GlobalConfig.SpeedTestUrl != "" {
+		stages = append(stages, stage{"speed", pc.stageWorkers(config.GlobalConfig.SpeedConcurrent), pc.checkSpeed})
+	}
+	return append(stages, stage{"media", pc.stageWorkers(config.GlobalConfig.MediaConcurrent), pc.checkMedia})
+}
+
+// stageWorkers 阶段的线程数，未设置时与 concurrent 相同，不超过节点数量
+func (pc *ProxyChecker) stageWorkers(n int) int {
+	if n <= 0 {
+		n = config.GlobalConfig.Concurrent
+	}
+	if pc.proxyCount > 0 && pc.proxyCount < n {
+		n = pc.proxyCount
+	}
+	return max(1, n)
+}
+
+// runStage 执行单个阶段，检测被取消时不再执行并记为未检测
+func runStage(ctx context.Context, s stage, t *stageTask) bool {
+	if ctx.Err() != nil {
+		fail(t.res, FailureSkipped)
+		return false
+	}
+	return s.check(ctx, t)
+}
+
+// startPipeline 为每个阶段启动独立的线程池，阶段之间通过通道连接
+// 最后一个阶段的线程全部退出后关闭 resultChan
+func (pc *ProxyChecker) startPipeline(ctx context.Context, stages []stage) {
+	in := pc.tasks
+	for i, s := range stages {
+		last := i == len(stages)-1
+		var out chan *stageTask
+		if !last {
+			// 缓冲与本阶段线程数相同，下游繁忙时本阶段的线程仍能完成手上的节点
+			out = make(chan *stageTask, s.workers)
+		}
+
+		var wg sync.WaitGroup
+		for range s.workers {
+			wg.Add(1)
+			go func(in <-chan *stageTask) {
+				defer wg.Done()
+				for t := range in {
+					if runStage(ctx, s, t) && !last {
+						out <- t
+						continue
+					}
+					pc.finish(t)
+				}
+			}(in)
+		}
+		go func() {
+			wg.Wait()
+			if last {
+				close(pc.resultChan)
+			} else {
+				close(out)
+			}
+		}()
+		slog.Debug(fmt.Sprintf("检测阶段 %s 线程数: %d", s.name, s.workers))
+		in = out
+	}
+}
+
+// finish 结束节点的检测，更新计数并发送结果
+func (pc *ProxyChecker) finish(t *stageTask) {
+	t.close()
+	result := t.res
+	if result.Failure == "" {
+		pc.incrementAvailable()
+		metrics.NodeLatency.Observe(float64(result.Latency))
+		if result.Speed > 0 {
+			metrics.NodeSpeed.Observe(float64(result.Speed))
+		}
+	}
+	publishNode(result)
+	pc.resultChan <- *result
+	pc.incrementProgress()
+}
+
+// checkProxy 依次执行所有阶段检测单个代理，失败时结果中记录失败原因
+// ctx 取消时请求立即中断，节点记为未检测
+func (pc *ProxyChecker) checkProxy(ctx context.Context, proxy map[string]any) *Result {
+	t := newStageTask(proxy)
+	defer t.close()
+	for _, s := range pc.stages() {
+		if !runStage(ctx, s, t) {
+			break
+		}
+	}
+	return t.res
+}
+
+// checkAlive 连通性检测，同时记录第一次延迟取样
+func (pc *ProxyChecker) checkAlive(ctx context.Context, t *stageTask) bool {
+	if os.Getenv("SUB_CHECK_SKIP") != "" {
+		// slog.Debug(fmt.Sprintf("跳过检测代理: %v", proxy["name"]))
+		return false
+	}
+
+	t.client = createClient(ctx, t.res.Proxy, pc.traffic)
+	if t.client == nil {
+		slog.Debug(fmt.Sprintf("创建代理Client失败: %v", t.res.Proxy["name"]))
+		fail(t.res, FailureParse)
+		return false
+	}
+
+	start := time.Now()
+	cloudflare, err := platform.CheckCloudflare(ctx, t.client.Client)
+	if err != nil || !cloudflare {
+		failError(ctx, t.res, err)
+		return false
+	}
+	t.res.Latency = int(time.Since(start).Milliseconds())
+	return true
+}
+
+// checkLatency 检测 Google 的连通性，并多次取样延迟，取最小值
+func (pc *ProxyChecker) checkLatency(ctx context.Context, t *stageTask) bool {
+	google, err := platform.CheckGoogle(ctx, t.client.Client)
+	if err != nil || !google {
+		failError(ctx, t.res, err)
+		return false
+	}
+
+	for i := 1; i < latencySamples; i++ {
+		start := time.Now()
+		if ok, err := platform.CheckCloudflare(ctx, t.client.Client); err != nil || !ok {
+			continue
+		}
+		t.res.Latency = min(t.res.Latency, int(time.Since(start).Milliseconds()))
+	}
+	return true
+}
+
+// checkSpeed 测速，受 total-speed-limit 限速
+func (pc *ProxyChecker) checkSpeed(ctx context.Context, t *stageTask) bool {
+	speed, _, err := platform.CheckSpeed(ctx, t.client.Client, pc.bucket)
+	t.res.Speed = speed
+	if err != nil {
+		failError(ctx, t.res, err)
+		return false
+	}
+	if speed < config.GlobalConfig.MinSpeed {
+		fail(t.res, FailureTooSlow)
+		return false
+	}
+	return true
+}
+
+// checkMedia 流媒体检测并更新节点名称，是最后一个阶段
+func (pc *ProxyChecker) checkMedia(ctx context.Context, t *stageTask) bool {
+	res := t.res
+	httpClient := t.client
+	// 遍历需要检测的平台
+	for _, plat := range pc.platforms {
+		switch plat {
+		case "openai":
+			cookiesOK, clientOK := platform.CheckOpenAI(ctx, httpClient.Client)
+			if clientOK && cookiesOK {
+				res.Openai = true
+			} else if cookiesOK || clientOK {
+				res.OpenaiWeb = true
+			}
+		case "youtube":
+			if region, _ := platform.CheckYoutube(ctx, httpClient.Client); region != "" {
+				res.Youtube = region
+			}
+		case "netflix":
+			if ok, _ := platform.CheckNetflix(ctx, httpClient.Client); ok {
+				res.Netflix = true
+			}
+		case "disney":
+			if ok, _ := platform.CheckDisney(ctx, httpClient.Client); ok {
+				res.Disney = true
+			}
+		case "gemini":
+			if ok, _ := platform.CheckGemini(ctx, httpClient.Client); ok {
+				res.Gemini = true
+			}
+		case "iprisk":
+			country, ip := proxyutils.GetProxyCountry(ctx, httpClient.Client)
+			if ip == "" {
+				break
+			}
+			res.IP = ip
+			res.Country = country
+			risk, err := platform.CheckIPRisk(ctx, httpClient.Client, ip)
+			if err == nil {
+				res.IPRisk = risk
+			} else {
+				// 失败的可能性高，所以放上日志
+				slog.Debug(fmt.Sprintf("查询IP风险失败: %v", err))
+			}
+		case "tiktok":
+			if region, _ := platform.CheckTikTok(ctx, httpClient.Client); region != "" {
+				res.TikTok = region
+			}
+		}
+	}
+	// 流媒体检测被中断时结果不完整，不作为可用节点
+	if ctx.Err() != nil {
+		fail(res, FailureSkipped)
+		return false
+	}
+	// 更新代理名称
+	pc.updateProxyName(ctx, res, httpClient, res.Speed)
+	return false
+}
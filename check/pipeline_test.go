@@ -0,0 +1,221 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+func TestStages(t *testing.T) {
+	saved := *config.GlobalConfig
+	t.Cleanup(func() { *config.GlobalConfig = saved })
+
+	tests := []struct {
+		name       string
+		speedURL   string
+		speed      int
+		proxyCount int
+		names      []string
+		workers    []int
+	}{
+		{"不测速", "", 0, 100, []string{"alive", "latency", "media"}, []int{20, 20, 5}},
+		{"测速", "http://example.com/file", 3, 100, []string{"alive", "latency", "speed", "media"}, []int{20, 20, 3, 5}},
+		{"节点少于线程数", "http://example.com/file", 3, 2, []string{"alive", "latency", "speed", "media"}, []int{2, 2, 2, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.GlobalConfig.Concurrent = 20
+			config.GlobalConfig.LatencyConcurrent = 0
+			config.GlobalConfig.SpeedConcurrent = tt.speed
+			config.GlobalConfig.MediaConcurrent = 5
+			config.GlobalConfig.SpeedTestUrl = tt.speedURL
+
+			pc := &ProxyChecker{proxyCount: tt.proxyCount}
+			var names []string
+			var workers []int
+			for _, s := range pc.stages() {
+				names = append(names, s.name)
+				workers = append(workers, s.workers)
+			}
+			if !slices.Equal(names, tt.names) {
+				t.Errorf("stages() = %v, want %v", names, tt.names)
+			}
+			if !slices.Equal(workers, tt.workers) {
+				t.Errorf("workers = %v, want %v", workers, tt.workers)
+			}
+		})
+	}
+}
+
+// stageRecorder 记录每个阶段检测过的节点
+type stageRecorder struct {
+	mu   sync.Mutex
+	seen map[string][]string
+}
+
+func (r *stageRecorder) record(stage string, t *stageTask) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seen == nil {
+		r.seen = make(map[string][]string)
+	}
+	r.seen[stage] = append(r.seen[stage], t.res.Proxy["name"].(string))
+}
+
+func (r *stageRecorder) names(stage string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.seen[stage]
+}
+
+// newTestChecker 创建只包含任务和结果通道的检测器
+func newTestChecker(proxyCount int) *ProxyChecker {
+	return &ProxyChecker{
+		proxyCount: proxyCount,
+		resultChan: make(chan Result),
+		tasks:      make(chan *stageTask),
+	}
+}
+
+// collectWithTimeout 读取 resultChan 直到关闭，超时视为死锁
+func collectWithTimeout(t *testing.T, pc *ProxyChecker) []Result {
+	t.Helper()
+	var results []Result
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case res, ok := <-pc.resultChan:
+			if !ok {
+				return results
+			}
+			results = append(results, res)
+		case <-timeout:
+			t.Fatalf("等待 resultChan 关闭超时，已收到 %d 个结果", len(results))
+		}
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	rec := &stageRecorder{}
+	stages := []stage{
+		{"alive", 4, func(ctx context.Context, t *stageTask) bool {
+			rec.record("alive", t)
+			if strings.HasPrefix(t.res.Proxy["name"].(string), "dead") {
+				fail(t.res, FailureTimeout)
+				return false
+			}
+			return true
+		}},
+		{"speed", 2, func(ctx context.Context, t *stageTask) bool {
+			rec.record("speed", t)
+			t.res.Speed = 1024
+			return true
+		}},
+		{"media", 3, func(ctx context.Context, t *stageTask) bool {
+			rec.record("media", t)
+			return false
+		}},
+	}
+
+	var proxies []map[string]any
+	for i := range 20 {
+		name := fmt.Sprintf("alive-%d", i)
+		if i%3 == 0 {
+			name = fmt.Sprintf("dead-%d", i)
+		}
+		proxies = append(proxies, map[string]any{"name": name, "type": "ss", "server": fmt.Sprintf("10.0.0.%d", i), "port": 443})
+	}
+
+	pc := newTestChecker(len(proxies))
+	pc.startPipeline(context.Background(), stages)
+	go pc.distributeProxies(context.Background(), proxies)
+	results := collectWithTimeout(t, pc)
+
+	// 每个节点只输出一次结果
+	count := make(map[string]int)
+	for _, res := range results {
+		name := res.Proxy["name"].(string)
+		count[name]++
+		dead := strings.HasPrefix(name, "dead")
+		if dead && res.Failure != FailureTimeout {
+			t.Errorf("%s Failure = %q, want %q", name, res.Failure, FailureTimeout)
+		}
+		if !dead && (res.Failure != "" || res.Speed != 1024) {
+			t.Errorf("%s Failure = %q, Speed = %d", name, res.Failure, res.Speed)
+		}
+	}
+	for _, proxy := range proxies {
+		if name := proxy["name"].(string); count[name] != 1 {
+			t.Errorf("%s 输出了 %d 次结果，want 1", name, count[name])
+		}
+	}
+	if pc.available != 13 {
+		t.Errorf("available = %d, want 13", pc.available)
+	}
+
+	// 失效的节点在连通性阶段淘汰，不进入测速和流媒体阶段
+	if n := len(rec.names("alive")); n != len(proxies) {
+		t.Errorf("alive 阶段检测了 %d 个节点，want %d", n, len(proxies))
+	}
+	for _, stage := range []string{"speed", "media"} {
+		names := rec.names(stage)
+		if len(names) != 13 {
+			t.Errorf("%s 阶段检测了 %d 个节点，want 13", stage, len(names))
+		}
+		if slices.ContainsFunc(names, func(name string) bool { return strings.HasPrefix(name, "dead") }) {
+			t.Errorf("%s 阶段检测了失效的节点: %v", stage, names)
+		}
+	}
+}
+
+func TestPipelineCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 流媒体阶段阻塞到检测被取消，模拟取消时仍在检测的节点
+	started := make(chan struct{})
+	var once sync.Once
+	stages := []stage{
+		{"alive", 2, func(ctx context.Context, t *stageTask) bool { return true }},
+		{"latency", 2, func(ctx context.Context, t *stageTask) bool { return true }},
+		{"media", 1, func(ctx context.Context, t *stageTask) bool {
+			once.Do(func() { close(started) })
+			<-ctx.Done()
+			failError(ctx, t.res, ctx.Err())
+			return false
+		}},
+	}
+
+	var proxies []map[string]any
+	for i := range 50 {
+		proxies = append(proxies, map[string]any{"name": fmt.Sprintf("node-%d", i), "type": "ss", "server": fmt.Sprintf("10.0.0.%d", i), "port": 443})
+	}
+
+	pc := newTestChecker(len(proxies))
+	pc.startPipeline(ctx, stages)
+	go pc.distributeProxies(ctx, proxies)
+	go func() {
+		<-started
+		cancel()
+	}()
+	results := collectWithTimeout(t, pc)
+
+	// 已经派发的节点都输出结果，且都记为未检测
+	if len(results) == 0 || len(results) != pc.dispatched {
+		t.Errorf("收到 %d 个结果，派发了 %d 个节点", len(results), pc.dispatched)
+	}
+	for _, res := range results {
+		if res.Failure != FailureSkipped {
+			t.Errorf("%v Failure = %q, want %q", res.Proxy["name"], res.Failure, FailureSkipped)
+		}
+	}
+	if pc.available != 0 {
+		t.Errorf("available = %d, want 0", pc.available)
+	}
+}
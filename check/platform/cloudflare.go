@@ -1,23 +1,26 @@
 package platform
 
 import (
+	"context"
 	"net/http"
 
 	"log/slog"
 )
 
-func CheckCloudflare(httpClient *http.Client) (bool, error) {
-	if success, err := checkCloudflareEndpoint(httpClient, "https://gstatic.com/generate_204", 204); err == nil && success {
-		// 不要判断这些网站，因为可能403
-		// return checkCloudflareEndpoint(httpClient, "https://www.cloudflare.com", 200)
-		return true, nil
+func CheckCloudflare(ctx context.Context, httpClient *http.Client) (bool, error) {
+	// 返回请求错误，方便判断失败原因
+	success, err := checkCloudflareEndpoint(ctx, httpClient, "https://gstatic.com/generate_204", 204)
+	if err != nil {
+		return false, err
 	}
-	return false, nil
+	// 不要判断这些网站，因为可能403
+	// return checkCloudflareEndpoint(ctx, httpClient, "https://www.cloudflare.com", 200)
+	return success, nil
 }
 
-func checkCloudflareEndpoint(httpClient *http.Client, url string, statusCode int) (bool, error) {
+func checkCloudflareEndpoint(ctx context.Context, httpClient *http.Client, url string, statusCode int) (bool, error) {
 	// 创建请求
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return false, err
 	}
@@ -1,14 +1,15 @@
 package platform
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"strings"
 )
 
 // https://github.com/clash-verge-rev/clash-verge-rev/blob/c894a15d13d5bcce518f8412cc393b56272a9afa/src-tauri/src/cmd/media_unlock_checker.rs#L241
-func CheckGemini(httpClient *http.Client) (bool, error) {
-	req, err := http.NewRequest("GET", "https://gemini.google.com/", nil)
+func CheckGemini(ctx context.Context, httpClient *http.Client) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://gemini.google.com/", nil)
 	if err != nil {
 		return false, err
 	}
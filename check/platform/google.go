@@ -1,9 +1,16 @@
 package platform
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
-func CheckGoogle(httpClient *http.Client) (bool, error) {
-	resp, err := httpClient.Get("http://www.google.com/generate_204")
+func CheckGoogle(ctx context.Context, httpClient *http.Client) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://www.google.com/generate_204", nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return false, err
 	}
@@ -1,6 +1,7 @@
 package platform
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,8 +10,8 @@ import (
 	"github.com/metacubex/mihomo/common/convert"
 )
 
-func CheckIPRisk(httpClient *http.Client, ip string) (string, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("https://scamalytics.com/ip/%s", ip), nil)
+func CheckIPRisk(ctx context.Context, httpClient *http.Client, ip string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://scamalytics.com/ip/%s", ip), nil)
 	if err != nil {
 		return "", err
 	}
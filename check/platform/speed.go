@@ -1,6 +1,7 @@
 package platform
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"math"
@@ -14,7 +15,7 @@ import (
 	"github.com/metacubex/mihomo/common/convert"
 )
 
-func CheckSpeed(httpClient *http.Client, bucket *ratelimit.Bucket) (int, int64, error) {
+func CheckSpeed(ctx context.Context, httpClient *http.Client, bucket *ratelimit.Bucket) (int, int64, error) {
 	// 创建一个新的测速专用客户端，基于原有客户端的传输层
 	speedClient := &http.Client{
 		// 设置更长的超时时间用于测速
@@ -23,7 +24,7 @@ func CheckSpeed(httpClient *http.Client, bucket *ratelimit.Bucket) (int, int64,
 		Transport: httpClient.Transport,
 	}
 
-	req, err := http.NewRequest("GET", config.GlobalConfig.SpeedTestUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", config.GlobalConfig.SpeedTestUrl, nil)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -1,13 +1,14 @@
 package platform
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"regexp"
 )
 
-func CheckTikTok(httpClient *http.Client) (string, error) {
-	req, err := http.NewRequest("GET", "https://www.tiktok.com/", nil)
+func CheckTikTok(ctx context.Context, httpClient *http.Client) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://www.tiktok.com/", nil)
 	if err != nil {
 		return "", err
 	}
@@ -1,6 +1,7 @@
 package platform
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"regexp"
@@ -10,9 +11,9 @@ import (
 // 在body中查找 INNERTUBE_CONTEXT_GL 并提取区域代码
 var re = regexp.MustCompile(`"INNERTUBE_CONTEXT_GL"\s*:\s*"([^"]+)"`)
 
-func CheckYoutube(httpClient *http.Client) (string, error) {
+func CheckYoutube(ctx context.Context, httpClient *http.Client) (string, error) {
 	// 创建请求
-	req, err := http.NewRequest("GET", "https://www.youtube.com/premium", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://www.youtube.com/premium", nil)
 	if err != nil {
 		return "", err
 	}
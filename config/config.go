@@ -1,68 +1,207 @@
 package config
 
-import _ "embed"
+import (
+	_ "embed"
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
 
 type Config struct {
-	PrintProgress        bool     `yaml:"print-progress"`
-	Concurrent           int      `yaml:"concurrent"`
-	CheckInterval        int      `yaml:"check-interval"`
-	CronExpression       string   `yaml:"cron-expression"`
-	SpeedTestUrl         string   `yaml:"speed-test-url"`
-	DownloadTimeout      int      `yaml:"download-timeout"`
-	DownloadMB           int      `yaml:"download-mb"`
-	TotalSpeedLimit      int      `yaml:"total-speed-limit"`
-	MinSpeed             int      `yaml:"min-speed"`
-	Timeout              int      `yaml:"timeout"`
-	FilterRegex          string   `yaml:"filter-regex"`
-	SaveMethod           string   `yaml:"save-method"`
-	WebDAVURL            string   `yaml:"webdav-url"`
-	WebDAVUsername       string   `yaml:"webdav-username"`
-	WebDAVPassword       string   `yaml:"webdav-password"`
-	GithubToken          string   `yaml:"github-token"`
-	GithubGistID         string   `yaml:"github-gist-id"`
-	GithubAPIMirror      string   `yaml:"github-api-mirror"`
-	WorkerURL            string   `yaml:"worker-url"`
-	WorkerToken          string   `yaml:"worker-token"`
-	S3Endpoint           string   `yaml:"s3-endpoint"`
-	S3AccessID           string   `yaml:"s3-access-id"`
-	S3SecretKey          string   `yaml:"s3-secret-key"`
-	S3Bucket             string   `yaml:"s3-bucket"`
-	S3UseSSL             bool     `yaml:"s3-use-ssl"`
-	S3BucketLookup       string   `yaml:"s3-bucket-lookup"`
-	SubUrlsReTry         int      `yaml:"sub-urls-retry"`
-	SubUrlsRetryInterval int      `yaml:"sub-urls-retry-interval"`
-	SubUrlsTimeout       int      `yaml:"sub-urls-timeout"`
-	SubUrlsRemote        []string `yaml:"sub-urls-remote"`
-	SubUrls              []string `yaml:"sub-urls"`
-	SuccessRate          float32  `yaml:"success-rate"`
-	MihomoApiUrl         string   `yaml:"mihomo-api-url"`
-	MihomoApiSecret      string   `yaml:"mihomo-api-secret"`
-	ListenPort           string   `yaml:"listen-port"`
-	RenameNode           bool     `yaml:"rename-node"`
-	KeepSuccessProxies   bool     `yaml:"keep-success-proxies"`
-	OutputDir            string   `yaml:"output-dir"`
-	AppriseApiServer     string   `yaml:"apprise-api-server"`
-	RecipientUrl         []string `yaml:"recipient-url"`
-	NotifyTitle          string   `yaml:"notify-title"`
-	MediaCheck           bool     `yaml:"media-check"`
-	Platforms            []string `yaml:"platforms"`
-	SuccessLimit         int32    `yaml:"success-limit"`
-	NodePrefix           string   `yaml:"node-prefix"`
-	NodeType             []string `yaml:"node-type"`
-	EnableWebUI          bool     `yaml:"enable-web-ui"`
-	APIKey               string   `yaml:"api-key"`
-	GithubProxy          string   `yaml:"github-proxy"`
-	Proxy                string   `yaml:"proxy"`
-	CallbackScript       string   `yaml:"callback-script"`
-	V2RaySubscription    bool     `yaml:"v2ray-subscription"`
+	PrintProgress        bool        `yaml:"print-progress"`
+	Concurrent           int         `yaml:"concurrent"`
+	LatencyConcurrent    int         `yaml:"latency-concurrent"`
+	SpeedConcurrent      int         `yaml:"speed-concurrent"`
+	MediaConcurrent      int         `yaml:"media-concurrent"`
+	CheckInterval        int         `yaml:"check-interval"`
+	CronExpression       string      `yaml:"cron-expression"`
+	SpeedTestUrl         string      `yaml:"speed-test-url"`
+	DownloadTimeout      int         `yaml:"download-timeout"`
+	DownloadMB           int         `yaml:"download-mb"`
+	TotalSpeedLimit      int         `yaml:"total-speed-limit"`
+	MinSpeed             int         `yaml:"min-speed"`
+	Timeout              int         `yaml:"timeout"`
+	FilterRegex          string      `yaml:"filter-regex"`
+	SaveMethod           SaveMethods `yaml:"save-method"`
+	BackendConfig        `yaml:",inline"`
+	SubUrlsReTry         int             `yaml:"sub-urls-retry"`
+	SubUrlsRetryInterval int             `yaml:"sub-urls-retry-interval"`
+	SubUrlsTimeout       int             `yaml:"sub-urls-timeout"`
+	SubUrlsRemote        []string        `yaml:"sub-urls-remote"`
+	SubUrls              []string        `yaml:"sub-urls"`
+	SuccessRate          float32         `yaml:"success-rate"`
+	MihomoApiUrl         string          `yaml:"mihomo-api-url"`
+	MihomoApiSecret      string          `yaml:"mihomo-api-secret"`
+	ListenPort           string          `yaml:"listen-port"`
+	RenameNode           bool            `yaml:"rename-node"`
+	KeepSuccessProxies   bool            `yaml:"keep-success-proxies"`
+	OutputDir            string          `yaml:"output-dir"`
+	AppriseApiServer     string          `yaml:"apprise-api-server"`
+	RecipientUrl         []string        `yaml:"recipient-url"`
+	NotifyTitle          string          `yaml:"notify-title"`
+	MediaCheck           bool            `yaml:"media-check"`
+	Platforms            []string        `yaml:"platforms"`
+	SuccessLimit         int32           `yaml:"success-limit"`
+	NodePrefix           string          `yaml:"node-prefix"`
+	NodeType             []string        `yaml:"node-type"`
+	EnableWebUI          bool            `yaml:"enable-web-ui"`
+	APIKey               string          `yaml:"api-key"`
+	EnableMetrics        bool            `yaml:"enable-metrics"`
+	GithubProxy          string          `yaml:"github-proxy"`
+	Proxy                string          `yaml:"proxy"`
+	CallbackScript       string          `yaml:"callback-script"`
+	V2RaySubscription    bool            `yaml:"v2ray-subscription"`
+	CountryGroupSort     string          `yaml:"country-group-sort"`
+	CountryGroupPinned   []string        `yaml:"country-group-pinned"`
+	CountryGroupMinNodes int             `yaml:"country-group-min-nodes"`
+	CountryGroupOther    bool            `yaml:"country-group-other"`
+	CountrySets          []CountrySet    `yaml:"country-sets"`
+	RuleTemplate         string          `yaml:"rule-template"`
+	RuleTemplates        []RuleTemplate  `yaml:"rule-templates"`
+	OutputProfiles       []OutputProfile `yaml:"output-profiles"`
+	SnapshotRetention    int             `yaml:"snapshot-retention"`
+	SnapshotDir          string          `yaml:"snapshot-dir"`
+	PublishMinNodes      int             `yaml:"publish-min-nodes"`
+	PublishMinRatio      int             `yaml:"publish-min-ratio"`
+	ReportCSV            bool            `yaml:"report-csv"`
+	ReportDir            string          `yaml:"report-dir"`
+	CheckpointInterval   int             `yaml:"checkpoint-interval"`
+	MaxRunDuration       int             `yaml:"max-run-duration"`
+}
+
+// OutputProfile 自定义输出配置
+type OutputProfile struct {
+	Name         string `yaml:"name"`
+	Filter       string `yaml:"filter"`
+	Format       string `yaml:"format"`
+	RuleTemplate string `yaml:"rule-template"`
+}
+
+// BackendConfig 远程保存后端的连接信息
+// 顶层的配置项是所有保存方法的默认值，save-method 中的单个保存方法可以覆盖
+type BackendConfig struct {
+	WebDAVURL        string `yaml:"webdav-url"`
+	WebDAVUsername   string `yaml:"webdav-username"`
+	WebDAVPassword   string `yaml:"webdav-password"`
+	GithubToken      string `yaml:"github-token"`
+	GithubGistID     string `yaml:"github-gist-id"`
+	GithubAPIMirror  string `yaml:"github-api-mirror"`
+	GithubGistPublic bool   `yaml:"github-gist-public"`
+	WorkerURL        string `yaml:"worker-url"`
+	WorkerToken      string `yaml:"worker-token"`
+	S3Endpoint       string `yaml:"s3-endpoint"`
+	S3AccessID       string `yaml:"s3-access-id"`
+	S3SecretKey      string `yaml:"s3-secret-key"`
+	S3Bucket         string `yaml:"s3-bucket"`
+	S3UseSSL         bool   `yaml:"s3-use-ssl"`
+	S3BucketLookup   string `yaml:"s3-bucket-lookup"`
+	SFTPHost         string `yaml:"sftp-host"`
+	SFTPUsername     string `yaml:"sftp-username"`
+	SFTPPassword     string `yaml:"sftp-password"`
+	SFTPPrivateKey   string `yaml:"sftp-private-key"`
+	SFTPPassphrase   string `yaml:"sftp-passphrase"`
+	SFTPHostKey      string `yaml:"sftp-host-key"`
+	SFTPDir          string `yaml:"sftp-dir"`
+	FTPHost          string `yaml:"ftp-host"`
+	FTPUsername      string `yaml:"ftp-username"`
+	FTPPassword      string `yaml:"ftp-password"`
+	FTPDir           string `yaml:"ftp-dir"`
+	FTPTLS           string `yaml:"ftp-tls"`
+	FTPSkipTLSVerify bool   `yaml:"ftp-skip-tls-verify"`
+	GitURL           string `yaml:"git-url"`
+	GitBranch        string `yaml:"git-branch"`
+	GitUsername      string `yaml:"git-username"`
+	GitToken         string `yaml:"git-token"`
+	GitSSHKey        string `yaml:"git-ssh-key"`
+	GitAuthorName    string `yaml:"git-author-name"`
+	GitAuthorEmail   string `yaml:"git-author-email"`
+	GitSquash        bool   `yaml:"git-squash"`
+}
+
+// SaveMethod 单个保存方法，name 为空时使用 type 作为名称
+// 未填写的连接信息使用顶层的同名配置项
+type SaveMethod struct {
+	Type          string `yaml:"type"`
+	Name          string `yaml:"name"`
+	BackendConfig `yaml:",inline"`
+}
+
+// Label 保存方法的名称，用于日志、指标和区分同类型的多个保存方法
+func (m SaveMethod) Label() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return m.Type
+}
+
+// Settings 返回合并后的连接信息，单个保存方法中填写的非空配置项覆盖顶层配置
+func (m SaveMethod) Settings(defaults BackendConfig) BackendConfig {
+	merged := defaults
+	dst := reflect.ValueOf(&merged).Elem()
+	src := reflect.ValueOf(m.BackendConfig)
+	for i := range src.NumField() {
+		if !src.Field(i).IsZero() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+	return merged
+}
+
+func (m *SaveMethod) UnmarshalYAML(value *yaml.Node) error {
+	// 兼容只填写类型名称的写法
+	if value.Kind == yaml.ScalarNode {
+		*m = SaveMethod{Type: value.Value}
+		return nil
+	}
+	type plain SaveMethod
+	var method plain
+	if err := value.Decode(&method); err != nil {
+		return err
+	}
+	if method.Type == "" {
+		return fmt.Errorf("第 %d 行的保存方法缺少 type", value.Line)
+	}
+	*m = SaveMethod(method)
+	return nil
+}
+
+// SaveMethods 保存方法列表，兼容旧版本单个字符串的写法
+type SaveMethods []SaveMethod
+
+func (s *SaveMethods) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*s = SaveMethods{{Type: value.Value}}
+		return nil
+	}
+	var methods []SaveMethod
+	if err := value.Decode(&methods); err != nil {
+		return err
+	}
+	*s = methods
+	return nil
+}
+
+// RuleTemplate 规则模板及其输出文件名
+type RuleTemplate struct {
+	Name     string `yaml:"name"`
+	Template string `yaml:"template"`
+}
+
+// CountrySet 自定义国家组
+type CountrySet struct {
+	Name      string   `yaml:"name"`
+	Countries []string `yaml:"countries"`
 }
 
 var GlobalConfig = &Config{
 	// 新增配置，给未更改配置文件的用户一个默认值
-	ListenPort:  ":8199",
-	NotifyTitle: "🔔 节点状态更新",
-	Platforms:   []string{"openai", "youtube", "netflix", "disney", "gemini", "iprisk"},
-	DownloadMB:  20,
+	ListenPort:         ":8199",
+	NotifyTitle:        "🔔 节点状态更新",
+	Platforms:          []string{"openai", "youtube", "netflix", "disney", "gemini", "iprisk"},
+	DownloadMB:         20,
+	SnapshotRetention:  10,
+	CheckpointInterval: 30,
 }
 
 //go:embed config.example.yaml
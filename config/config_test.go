@@ -0,0 +1,78 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSaveMethods(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    []string // 每个保存方法的 Label
+		wantErr bool
+	}{
+		{name: "单个字符串", yaml: "save-method: webdav", want: []string{"webdav"}},
+		{name: "字符串列表", yaml: "save-method: [local, gist]", want: []string{"local", "gist"}},
+		{
+			name: "混合写法",
+			yaml: "save-method:\n  - local\n  - type: webdav\n    name: nas\n    webdav-url: http://nas/dav/\n  - type: webdav\n",
+			want: []string{"local", "nas", "webdav"},
+		},
+		{name: "缺少 type", yaml: "save-method:\n  - name: nas\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			err := yaml.Unmarshal([]byte(tt.yaml), &cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, m := range cfg.SaveMethod {
+				got = append(got, m.Label())
+			}
+			if !tt.wantErr && len(got) != len(tt.want) {
+				t.Fatalf("save-method = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("save-method = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSaveMethodSettings(t *testing.T) {
+	var cfg Config
+	data := `
+webdav-url: http://default/dav/
+webdav-username: admin
+webdav-password: secret
+save-method:
+  - type: webdav
+    name: nas
+    webdav-url: http://nas/dav/
+    webdav-username: nas
+`
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	got := cfg.SaveMethod[0].Settings(cfg.BackendConfig)
+	if got.WebDAVURL != "http://nas/dav/" || got.WebDAVUsername != "nas" || got.WebDAVPassword != "secret" {
+		t.Errorf("Settings() = %+v", got)
+	}
+	if cfg.WebDAVURL != "http://default/dav/" {
+		t.Errorf("顶层 webdav-url = %q, 不应被修改", cfg.WebDAVURL)
+	}
+}
+
+func TestDefaultConfigTemplate(t *testing.T) {
+	var cfg Config
+	if err := yaml.Unmarshal(DefaultConfigTemplate, &cfg); err != nil {
+		t.Fatalf("config.example.yaml 解析失败: %v", err)
+	}
+}
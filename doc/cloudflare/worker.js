@@ -67,7 +67,20 @@ const routeHandlers = {
             return handleError('未授权访问', 401);
         }
 
-        if (request.method === 'GET') {
+        if (request.method === 'GET' && url.searchParams.get('action') === 'list') {
+            try {
+                const keys = [];
+                let cursor;
+                do {
+                    const listed = await env.SUB_BUCKET.list({ cursor });
+                    keys.push(...listed.objects.map(object => object.key));
+                    cursor = listed.truncated ? listed.cursor : undefined;
+                } while (cursor);
+                return createResponse({ keys });
+            } catch (error) {
+                return handleError('列出文件失败: ' + error.message);
+            }
+        } else if (request.method === 'GET') {
             const filename = url.searchParams.get('filename');
             if (!filename) {
                 return handleError('请提供文件名', 400);
@@ -94,6 +107,18 @@ const routeHandlers = {
             } catch (error) {
                 return handleError('数据写入失败: ' + error.message);
             }
+        } else if (request.method === 'DELETE') {
+            const filename = url.searchParams.get('filename');
+            if (!filename) {
+                return handleError('请提供文件名', 400);
+            }
+
+            try {
+                await env.SUB_BUCKET.delete(filename);
+                return createResponse({ code: 200, message: '数据删除成功' });
+            } catch (error) {
+                return handleError('数据删除失败: ' + error.message);
+            }
         }
 
         return handleError('不支持的请求方法', 405);
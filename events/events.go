@@ -0,0 +1,105 @@
+// Package events 向订阅者广播检测过程中的事件，用于 /api/events 实时推送
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 事件类型
+const (
+	RunStarted          = "run-started"
+	NodeChecked         = "node-checked"
+	SubscriptionFetched = "subscription-fetched"
+	SaveCompleted       = "save-completed"
+	RunFinished         = "run-finished"
+)
+
+// Event 单个事件
+type Event struct {
+	ID   uint64    `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data,omitempty"`
+}
+
+// NodeCheckedData 单个节点检测完成
+type NodeCheckedData struct {
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	Subscription string   `json:"subscription,omitempty"`
+	Passed       bool     `json:"passed"`
+	Reason       string   `json:"reason,omitempty"`
+	Latency      int      `json:"latency,omitempty"`
+	Speed        int      `json:"speed,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+}
+
+// SubscriptionFetchedData 单个订阅获取完成，订阅只包含备注或域名，避免泄露订阅token
+type SubscriptionFetchedData struct {
+	Subscription string `json:"subscription"`
+	Nodes        int    `json:"nodes"`
+	Error        string `json:"error,omitempty"`
+}
+
+// SaveCompletedData 保存完成
+type SaveCompletedData struct {
+	Nodes  int      `json:"nodes"`
+	Files  int      `json:"files"`
+	Failed []string `json:"failed,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// RunFinishedData 检测结束
+type RunFinishedData struct {
+	Total     int     `json:"total"`
+	Available int     `json:"available"`
+	Duration  float64 `json:"duration"`
+	Traffic   uint64  `json:"traffic"`
+	Partial   bool    `json:"partial,omitempty"` // 达到最大运行时长或被取消，只完成了部分节点
+	Error     string  `json:"error,omitempty"`
+}
+
+// 每个订阅者的缓冲大小，订阅者处理不过来时丢弃事件，不阻塞检测
+const bufferSize = 256
+
+var (
+	mu          sync.RWMutex
+	subscribers = make(map[chan Event]struct{})
+	nextID      atomic.Uint64
+)
+
+// Subscribe 订阅事件，返回事件通道和取消订阅的函数
+func Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, bufferSize)
+	mu.Lock()
+	subscribers[ch] = struct{}{}
+	mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			mu.Lock()
+			delete(subscribers, ch)
+			mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish 广播事件，没有订阅者时直接返回
+func Publish(typ string, data any) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(subscribers) == 0 {
+		return
+	}
+	event := Event{ID: nextID.Add(1), Type: typ, Time: time.Now(), Data: data}
+	for ch := range subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package events
+
+import "testing"
+
+func TestPublishSubscribe(t *testing.T) {
+	ch, unsubscribe := Subscribe()
+	Publish(NodeChecked, NodeCheckedData{Name: "a", Passed: true})
+	Publish(RunFinished, RunFinishedData{Total: 1, Available: 1})
+
+	first, second := <-ch, <-ch
+	if first.Type != NodeChecked || second.Type != RunFinished {
+		t.Errorf("got types %q, %q", first.Type, second.Type)
+	}
+	if second.ID <= first.ID {
+		t.Errorf("ids not increasing: %d, %d", first.ID, second.ID)
+	}
+	if data, ok := first.Data.(NodeCheckedData); !ok || data.Name != "a" {
+		t.Errorf("unexpected data: %#v", first.Data)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-ch; ok {
+		t.Errorf("channel not closed after unsubscribe")
+	}
+	Publish(RunStarted, nil)
+}
+
+func TestPublishDropsWhenFull(t *testing.T) {
+	ch, unsubscribe := Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < bufferSize+10; i++ {
+		Publish(NodeChecked, nil)
+	}
+	if len(ch) != bufferSize {
+		t.Errorf("buffered %d events, want %d", len(ch), bufferSize)
+	}
+}
@@ -0,0 +1,243 @@
+// Package metrics 以 Prometheus 文本格式导出运行指标
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// collector 单个指标的输出
+type collector struct {
+	name  string
+	help  string
+	typ   string
+	write func(w io.Writer, name string)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+func register(name, help, typ string, write func(w io.Writer, name string)) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, collector{name: name, help: help, typ: typ, write: write})
+}
+
+// Gauge 可增可减的数值
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+// NewGauge 创建并注册 Gauge
+func NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	register(name, help, "gauge", func(w io.Writer, name string) {
+		writeSample(w, name, "", g.Value())
+	})
+	return g
+}
+
+// Set 设置当前值
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+// Value 返回当前值
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+// Counter 只增不减的计数
+type Counter struct {
+	bits atomic.Uint64
+}
+
+// NewCounter 创建并注册 Counter
+func NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	register(name, help, "counter", func(w io.Writer, name string) {
+		writeSample(w, name, "", c.Value())
+	})
+	return c
+}
+
+// Add 增加计数，负数会被忽略
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	for {
+		old := c.bits.Load()
+		if c.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// Inc 计数加一
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Value 返回当前值
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// NewGaugeFunc 注册一个读取时才计算的 Gauge
+func NewGaugeFunc(name, help string, fn func() float64) {
+	register(name, help, "gauge", func(w io.Writer, name string) {
+		writeSample(w, name, "", fn())
+	})
+}
+
+// Vec 带一个标签的一组数值，Gauge 和 Counter 共用
+type Vec struct {
+	label  string
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newVec(name, help, typ, label string) *Vec {
+	v := &Vec{label: label, values: make(map[string]float64)}
+	register(name, help, typ, func(w io.Writer, name string) {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		keys := make([]string, 0, len(v.values))
+		for key := range v.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writeSample(w, name, formatLabel(v.label, key), v.values[key])
+		}
+	})
+	return v
+}
+
+// NewGaugeVec 创建并注册带标签的 Gauge
+func NewGaugeVec(name, help, label string) *Vec {
+	return newVec(name, help, "gauge", label)
+}
+
+// NewCounterVec 创建并注册带标签的 Counter
+func NewCounterVec(name, help, label string) *Vec {
+	return newVec(name, help, "counter", label)
+}
+
+// Add 为标签值增加数值
+func (v *Vec) Add(value string, delta float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[value] += delta
+}
+
+// Inc 为标签值加一
+func (v *Vec) Inc(value string) {
+	v.Add(value, 1)
+}
+
+// Replace 用新的数据替换全部标签值，用于每次运行结束后更新
+func (v *Vec) Replace(values map[string]int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values = make(map[string]float64, len(values))
+	for key, value := range values {
+		v.values[key] = float64(value)
+	}
+}
+
+// Histogram 分布统计，桶上限升序排列
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// NewHistogram 创建并注册 Histogram
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+	register(name, help, "histogram", func(w io.Writer, name string) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += h.counts[i]
+			writeSample(w, name+"_bucket", formatLabel("le", formatFloat(bound)), float64(cumulative))
+		}
+		writeSample(w, name+"_bucket", formatLabel("le", "+Inf"), float64(h.count))
+		writeSample(w, name+"_sum", "", h.sum)
+		writeSample(w, name+"_count", "", float64(h.count))
+	})
+	return h
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// WriteTo 按 Prometheus 文本格式输出所有指标
+func WriteTo(w io.Writer) {
+	registryMu.Lock()
+	collectors := append([]collector(nil), registry...)
+	registryMu.Unlock()
+
+	for _, c := range collectors {
+		fmt.Fprintf(w, "# HELP %s %s\n", c.name, c.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", c.name, c.typ)
+		c.write(w, c.name)
+	}
+}
+
+// Handler 返回 /metrics 的 HTTP 处理函数
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteTo(w)
+	})
+}
+
+func writeSample(w io.Writer, name, labels string, value float64) {
+	if labels != "" {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, labels, formatFloat(value))
+		return
+	}
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(value))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabel(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	gauge := NewGauge("test_gauge", "测试 gauge")
+	gauge.Set(1.5)
+	counter := NewCounter("test_counter_total", "测试 counter")
+	counter.Add(2)
+	counter.Add(-1)
+	vec := NewCounterVec("test_vec_total", "测试 vec", "backend")
+	vec.Inc("s3")
+	vec.Inc(`a"b`)
+	hist := NewHistogram("test_hist", "测试 histogram", []float64{10, 100})
+	hist.Observe(5)
+	hist.Observe(50)
+	hist.Observe(500)
+
+	var buf bytes.Buffer
+	WriteTo(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE test_gauge gauge\ntest_gauge 1.5\n",
+		"# TYPE test_counter_total counter\ntest_counter_total 2\n",
+		`test_vec_total{backend="a\"b"} 1` + "\n" + `test_vec_total{backend="s3"} 1` + "\n",
+		`test_hist_bucket{le="10"} 1` + "\n",
+		`test_hist_bucket{le="100"} 2` + "\n",
+		`test_hist_bucket{le="+Inf"} 3` + "\n",
+		"test_hist_sum 555\ntest_hist_count 3\n",
+		"# TYPE subs_check_nodes_alive gauge\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WriteTo() missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import "github.com/beck-8/subs-check/utils"
+
+// 检测相关指标，每次运行结束后更新
+var (
+	Runs            = NewCounter("subs_check_runs_total", "检测运行次数")
+	RunDuration     = NewGauge("subs_check_run_duration_seconds", "上一次检测耗时(秒)")
+	RunLastFinished = NewGauge("subs_check_run_last_finished_timestamp_seconds", "上一次检测完成的时间戳")
+	NodesFetched    = NewGauge("subs_check_nodes_fetched", "上一次检测获取的节点数量")
+	NodesDeduped    = NewGauge("subs_check_nodes_deduped", "上一次检测去重后的节点数量")
+	NodesAlive      = NewGauge("subs_check_nodes_alive", "上一次检测的可用节点数量")
+	TrafficBytes    = NewCounter("subs_check_traffic_bytes_total", "检测消耗的总流量(字节)")
+	RunPartial      = NewGauge("subs_check_run_partial", "上一次检测是否因达到最大运行时长或被取消而只完成了部分节点(1 为是)")
+
+	SubscriptionAlive = NewGaugeVec("subs_check_subscription_alive_nodes", "上一次检测各订阅的可用节点数量", "subscription")
+	PlatformUnlocked  = NewGaugeVec("subs_check_platform_unlocked_nodes", "上一次检测各平台解锁的节点数量", "platform")
+	CountryAlive      = NewGaugeVec("subs_check_country_alive_nodes", "上一次检测各国家的可用节点数量", "country")
+
+	NodeLatency = NewHistogram("subs_check_node_latency_milliseconds", "可用节点的连通性检测延迟(毫秒)",
+		[]float64{100, 200, 300, 500, 800, 1000, 1500, 2000, 3000, 5000})
+	NodeSpeed = NewHistogram("subs_check_node_speed_kbps", "可用节点的测速结果(KB/s)",
+		[]float64{512, 1024, 2048, 5120, 10240, 20480, 51200})
+
+	SaveFailures = NewCounterVec("subs_check_save_failures_total", "保存到各后端失败的次数，每次保存每个后端最多计一次", "backend")
+)
+
+// SetNodeStats 根据统计数据更新国家、平台和订阅的节点数量
+func SetNodeStats(stats *utils.StatsData) {
+	NodesAlive.Set(float64(stats.TotalNodes))
+	CountryAlive.Replace(stats.Countries)
+	PlatformUnlocked.Replace(stats.Platforms)
+	SubscriptionAlive.Replace(stats.Subscriptions)
+}
@@ -1,6 +1,8 @@
 package proxies
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 )
 
@@ -9,18 +11,10 @@ func DeduplicateProxies(proxies []map[string]any) []map[string]any {
 	result := make([]map[string]any, 0, len(proxies))
 
 	for _, proxy := range proxies {
-		server, _ := proxy["server"].(string)
-		if server == "" {
+		key := proxyKey(proxy)
+		if key == "" {
 			continue
 		}
-		servername, _ := proxy["servername"].(string)
-
-		password, _ := proxy["password"].(string)
-		if password == "" {
-			password, _ = proxy["uuid"].(string)
-		}
-
-		key := fmt.Sprintf("%s:%v:%s:%s", server, proxy["port"], servername, password)
 		if !seenKeys[key] {
 			seenKeys[key] = true
 			result = append(result, proxy)
@@ -29,3 +23,25 @@ func DeduplicateProxies(proxies []map[string]any) []map[string]any {
 
 	return result
 }
+
+// proxyKey 去重使用的节点标识，没有 server 时返回空
+func proxyKey(proxy map[string]any) string {
+	server, _ := proxy["server"].(string)
+	if server == "" {
+		return ""
+	}
+	servername, _ := proxy["servername"].(string)
+
+	password, _ := proxy["password"].(string)
+	if password == "" {
+		password, _ = proxy["uuid"].(string)
+	}
+
+	return fmt.Sprintf("%s:%v:%s:%s", server, proxy["port"], servername, password)
+}
+
+// ProxyID 节点的稳定ID，由去重标识哈希得到，不包含密码等明文，节点改名后也不会变化
+func ProxyID(proxy map[string]any) string {
+	sum := sha256.Sum256([]byte(proxyKey(proxy)))
+	return hex.EncodeToString(sum[:6])
+}
@@ -3,27 +3,27 @@ package proxies
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
-	"net/http"
 	u "net/url"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/events"
 	"github.com/beck-8/subs-check/utils"
 	"github.com/metacubex/mihomo/common/convert"
 	"github.com/samber/lo"
 	"gopkg.in/yaml.v3"
 )
 
-func GetProxies() ([]map[string]any, error) {
+// GetProxies 获取所有订阅的节点，ctx 取消时停止获取并返回 ctx 的错误
+func GetProxies(ctx context.Context) ([]map[string]any, error) {
 
 	// 解析本地与远程订阅清单
-	subUrls := resolveSubUrls()
+	subUrls := resolveSubUrls(ctx)
 	slog.Info("订阅链接数量", "本地", len(config.GlobalConfig.SubUrls), "远程", len(config.GlobalConfig.SubUrlsRemote), "总计", len(subUrls))
 
 	if len(config.GlobalConfig.NodeType) > 0 {
@@ -46,83 +46,27 @@ func GetProxies() ([]map[string]any, error) {
 
 	// 启动工作协程
 	for _, subUrl := range subUrls {
+		if !acquire(ctx, concurrentLimit) {
+			break
+		}
 		wg.Add(1)
-		concurrentLimit <- struct{}{} // 获取令牌
 
 		go func(url string) {
 			defer wg.Done()
 			defer func() { <-concurrentLimit }() // 释放令牌
 
-			data, err := GetDateFromSubs(url)
-			if err != nil {
-				slog.Error(fmt.Sprintf("获取订阅链接错误跳过: %v", err))
-				return
-			}
-
 			var tag string
 			if d, err := u.Parse(url); err == nil {
 				tag = d.Fragment
 			}
 
-			var con map[string]any
-			err = yaml.Unmarshal(data, &con)
+			count, err := fetchSubscription(ctx, url, tag, proxyChan)
+			data := events.SubscriptionFetchedData{Subscription: utils.SubscriptionName(url, tag), Nodes: count}
 			if err != nil {
-				proxyList, err := convert.ConvertsV2Ray(data)
-				if err != nil {
-					slog.Error(fmt.Sprintf("解析proxy错误: %v", err), "url", url)
-					return
-				}
-				slog.Debug(fmt.Sprintf("获取订阅链接: %s，有效节点数量: %d", url, len(proxyList)))
-				for _, proxy := range proxyList {
-					// 只测试指定协议
-					if t, ok := proxy["type"].(string); ok {
-						if len(config.GlobalConfig.NodeType) > 0 && !lo.Contains(config.GlobalConfig.NodeType, t) {
-							continue
-						}
-					}
-
-					// 为每个节点添加订阅链接来源信息和备注
-					proxy["sub_url"] = url
-					proxy["sub_tag"] = tag
-					proxyChan <- proxy
-				}
-				return
-			}
-
-			proxyInterface, ok := con["proxies"]
-			if !ok || proxyInterface == nil {
-				slog.Error(fmt.Sprintf("订阅链接没有proxies: %s", url))
-				return
-			}
-
-			proxyList, ok := proxyInterface.([]any)
-			if !ok {
-				return
-			}
-			slog.Debug(fmt.Sprintf("获取订阅链接: %s，有效节点数量: %d", url, len(proxyList)))
-			for _, proxy := range proxyList {
-				if proxyMap, ok := proxy.(map[string]any); ok {
-					if t, ok := proxyMap["type"].(string); ok {
-						// 只测试指定协议
-						if len(config.GlobalConfig.NodeType) > 0 && !lo.Contains(config.GlobalConfig.NodeType, t) {
-							continue
-						}
-						// 虽然支持mihomo支持下划线，但是这里为了规范，还是改成横杠
-						// todo: 不知道后边还有没有这类问题
-						switch t {
-						case "hysteria2", "hy2":
-							if _, ok := proxyMap["obfs_password"]; ok {
-								proxyMap["obfs-password"] = proxyMap["obfs_password"]
-								delete(proxyMap, "obfs_password")
-							}
-						}
-					}
-					// 为每个节点添加订阅链接来源信息和备注
-					proxyMap["sub_url"] = url
-					proxyMap["sub_tag"] = tag
-					proxyChan <- proxyMap
-				}
+				// 错误信息中可能包含完整的订阅链接，替换为订阅名称
+				data.Error = strings.ReplaceAll(err.Error(), url, data.Subscription)
 			}
+			events.Publish(events.SubscriptionFetched, data)
 		}(utils.WarpUrl(subUrl))
 	}
 
@@ -131,12 +75,107 @@ func GetProxies() ([]map[string]any, error) {
 	close(proxyChan)
 	<-done // 等待收集完成
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return mihomoProxies, nil
 }
 
+// acquire 获取令牌，等待期间或获取后发现已取消时返回 false，且不占用令牌
+func acquire(ctx context.Context, limit chan struct{}) bool {
+	select {
+	case limit <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+	if ctx.Err() != nil {
+		<-limit
+		return false
+	}
+	return true
+}
+
+// fetchSubscription 获取并解析单个订阅，将节点发送到 proxyChan，返回发送的节点数量
+func fetchSubscription(ctx context.Context, url, tag string, proxyChan chan<- map[string]any) (int, error) {
+	data, err := GetDateFromSubs(ctx, url)
+	if err != nil {
+		slog.Error(fmt.Sprintf("获取订阅链接错误跳过: %v", err))
+		return 0, err
+	}
+
+	proxyList, err := ParseSubscription(data)
+	if err != nil {
+		slog.Error(fmt.Sprintf("解析订阅错误: %v", err), "url", url)
+		return 0, err
+	}
+	slog.Debug(fmt.Sprintf("获取订阅链接: %s，有效节点数量: %d", url, len(proxyList)))
+	for _, proxy := range proxyList {
+		// 为每个节点添加订阅链接来源信息和备注
+		proxy["sub_url"] = url
+		proxy["sub_tag"] = tag
+		proxyChan <- proxy
+	}
+	return len(proxyList), nil
+}
+
+// ParseSubscription 解析订阅内容，支持 mihomo 的 yaml 和 v2ray 格式，只保留 node-type 指定的协议
+func ParseSubscription(data []byte) ([]map[string]any, error) {
+	var con map[string]any
+	err := yaml.Unmarshal(data, &con)
+	if err != nil {
+		proxyList, err := convert.ConvertsV2Ray(data)
+		if err != nil {
+			return nil, fmt.Errorf("解析proxy错误: %w", err)
+		}
+		result := make([]map[string]any, 0, len(proxyList))
+		for _, proxy := range proxyList {
+			// 只测试指定协议
+			if t, ok := proxy["type"].(string); ok {
+				if len(config.GlobalConfig.NodeType) > 0 && !lo.Contains(config.GlobalConfig.NodeType, t) {
+					continue
+				}
+			}
+			result = append(result, proxy)
+		}
+		return result, nil
+	}
+
+	proxyInterface, ok := con["proxies"]
+	if !ok || proxyInterface == nil {
+		return nil, errors.New("订阅中没有proxies")
+	}
+
+	proxyList, ok := proxyInterface.([]any)
+	if !ok {
+		return nil, errors.New("订阅中的proxies格式错误")
+	}
+	result := make([]map[string]any, 0, len(proxyList))
+	for _, proxy := range proxyList {
+		if proxyMap, ok := proxy.(map[string]any); ok {
+			if t, ok := proxyMap["type"].(string); ok {
+				// 只测试指定协议
+				if len(config.GlobalConfig.NodeType) > 0 && !lo.Contains(config.GlobalConfig.NodeType, t) {
+					continue
+				}
+				// 虽然支持mihomo支持下划线，但是这里为了规范，还是改成横杠
+				// todo: 不知道后边还有没有这类问题
+				switch t {
+				case "hysteria2", "hy2":
+					if _, ok := proxyMap["obfs_password"]; ok {
+						proxyMap["obfs-password"] = proxyMap["obfs_password"]
+						delete(proxyMap, "obfs_password")
+					}
+				}
+			}
+			result = append(result, proxyMap)
+		}
+	}
+	return result, nil
+}
+
 // from 3k
 // resolveSubUrls 合并本地与远程订阅清单并去重
-func resolveSubUrls() []string {
+func resolveSubUrls(ctx context.Context) []string {
 	urls := make([]string, 0, len(config.GlobalConfig.SubUrls))
 	// 本地配置
 	urls = append(urls, config.GlobalConfig.SubUrls...)
@@ -144,7 +183,7 @@ func resolveSubUrls() []string {
 	// 远程清单
 	if len(config.GlobalConfig.SubUrlsRemote) != 0 {
 		for _, d := range config.GlobalConfig.SubUrlsRemote {
-			if remote, err := fetchRemoteSubUrls(utils.WarpUrl(d)); err != nil {
+			if remote, err := fetchRemoteSubUrls(ctx, utils.WarpUrl(d)); err != nil {
 				slog.Warn("获取远程订阅清单失败，已忽略", "err", err)
 			} else {
 				urls = append(urls, remote...)
@@ -174,11 +213,11 @@ func resolveSubUrls() []string {
 // 支持两种格式：
 // 1) 纯文本，按换行分隔，支持以 # 开头的注释与空行
 // 2) YAML/JSON 的字符串数组
-func fetchRemoteSubUrls(listURL string) ([]string, error) {
+func fetchRemoteSubUrls(ctx context.Context, listURL string) ([]string, error) {
 	if listURL == "" {
 		return nil, errors.New("empty list url")
 	}
-	data, err := GetDateFromSubs(listURL)
+	data, err := GetDateFromSubs(ctx, listURL)
 	if err != nil {
 		return nil, err
 	}
@@ -206,55 +245,6 @@ func fetchRemoteSubUrls(listURL string) ([]string, error) {
 }
 
 // 订阅链接中获取数据
-func GetDateFromSubs(subUrl string) ([]byte, error) {
-	maxRetries := config.GlobalConfig.SubUrlsReTry
-	// 重试间隔
-	retryInterval := config.GlobalConfig.SubUrlsRetryInterval
-	if retryInterval == 0 {
-		retryInterval = 1
-	}
-	// 超时时间
-	timeout := config.GlobalConfig.SubUrlsTimeout
-	if timeout == 0 {
-		timeout = 10
-	}
-	var lastErr error
-
-	client := &http.Client{
-		Timeout: time.Duration(timeout) * time.Second,
-	}
-
-	for i := 0; i < maxRetries; i++ {
-		if i > 0 {
-			time.Sleep(time.Duration(retryInterval) * time.Second)
-		}
-
-		req, err := http.NewRequest("GET", subUrl, nil)
-		if err != nil {
-			lastErr = err
-			continue
-		}
-
-		req.Header.Set("User-Agent", "clash.meta")
-
-		resp, err := client.Do(req)
-		if err != nil {
-			lastErr = err
-			continue
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode != 200 {
-			lastErr = fmt.Errorf("订阅链接: %s 返回状态码: %d", subUrl, resp.StatusCode)
-			continue
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			lastErr = fmt.Errorf("读取订阅链接: %s 数据错误: %v", subUrl, err)
-			continue
-		}
-		return body, nil
-	}
-
-	return nil, fmt.Errorf("重试%d次后失败: %v", maxRetries, lastErr)
+func GetDateFromSubs(ctx context.Context, subUrl string) ([]byte, error) {
+	return utils.FetchWithRetry(ctx, subUrl)
 }
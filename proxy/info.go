@@ -1,6 +1,7 @@
 package proxies
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,22 +14,22 @@ import (
 	"github.com/metacubex/mihomo/common/convert"
 )
 
-func GetProxyCountry(httpClient *http.Client) (loc string, ip string) {
+func GetProxyCountry(ctx context.Context, httpClient *http.Client) (loc string, ip string) {
 	for i := 0; i < config.GlobalConfig.SubUrlsReTry; i++ {
-		loc, ip = GetMe(httpClient)
+		loc, ip = GetMe(ctx, httpClient)
 		if loc != "" && ip != "" {
 			return
 		}
-		loc, ip = GetIPLark(httpClient)
+		loc, ip = GetIPLark(ctx, httpClient)
 		if loc != "" && ip != "" {
 			return
 		}
-		loc, ip = GetCFProxy(httpClient)
+		loc, ip = GetCFProxy(ctx, httpClient)
 		if loc != "" && ip != "" {
 			return
 		}
 		// 不准
-		loc, ip = GetEdgeOneProxy(httpClient)
+		loc, ip = GetEdgeOneProxy(ctx, httpClient)
 		if loc != "" && ip != "" {
 			return
 		}
@@ -36,7 +37,7 @@ func GetProxyCountry(httpClient *http.Client) (loc string, ip string) {
 	return
 }
 
-func GetEdgeOneProxy(httpClient *http.Client) (loc string, ip string) {
+func GetEdgeOneProxy(ctx context.Context, httpClient *http.Client) (loc string, ip string) {
 	type GeoResponse struct {
 		Eo struct {
 			Geo struct {
@@ -47,13 +48,13 @@ func GetEdgeOneProxy(httpClient *http.Client) (loc string, ip string) {
 	}
 
 	url := "https://functions-geolocation.edgeone.app/geo"
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("创建请求失败: %s", err))
 		return
 	}
 	req.Header.Set("User-Agent", convert.RandUserAgent())
-	resp, err := httpClient.Get(url)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("edgeone获取节点位置失败: %s", err))
 		return
@@ -81,15 +82,15 @@ func GetEdgeOneProxy(httpClient *http.Client) (loc string, ip string) {
 	return eo.Eo.Geo.CountryCodeAlpha2, eo.Eo.ClientIp
 }
 
-func GetCFProxy(httpClient *http.Client) (loc string, ip string) {
+func GetCFProxy(ctx context.Context, httpClient *http.Client) (loc string, ip string) {
 	url := "https://www.cloudflare.com/cdn-cgi/trace"
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("创建请求失败: %s", err))
 		return
 	}
 	req.Header.Set("User-Agent", convert.RandUserAgent())
-	resp, err := httpClient.Get(url)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("cf获取节点位置失败: %s", err))
 		return
@@ -119,14 +120,14 @@ func GetCFProxy(httpClient *http.Client) (loc string, ip string) {
 	return
 }
 
-func GetIPLark(httpClient *http.Client) (loc string, ip string) {
+func GetIPLark(ctx context.Context, httpClient *http.Client) (loc string, ip string) {
 	type GeoIPData struct {
 		IP      string `json:"ip"`
 		Country string `json:"country_code"`
 	}
 
 	url := string([]byte{104, 116, 116, 112, 115, 58, 47, 47, 102, 51, 98, 99, 97, 48, 101, 50, 56, 101, 54, 98, 46, 97, 97, 112, 113, 46, 110, 101, 116, 47, 105, 112, 97, 112, 105, 47, 105, 112, 99, 97, 116})
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("创建请求失败: %s", err))
 		return
@@ -160,14 +161,14 @@ func GetIPLark(httpClient *http.Client) (loc string, ip string) {
 	return geo.Country, geo.IP
 }
 
-func GetMe(httpClient *http.Client) (loc string, ip string) {
+func GetMe(ctx context.Context, httpClient *http.Client) (loc string, ip string) {
 	type GeoIPData struct {
 		IP      string `json:"ip"`
 		Country string `json:"country_code"`
 	}
 
 	url := "https://ip.122911.xyz/api/ipinfo"
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("创建请求失败: %s", err))
 		return
@@ -27,6 +27,16 @@ func ResetRenameCounter() {
 	counter = make(map[string]int)
 }
 
+// RestoreRenameCounter 恢复中断的检测时设置已使用的编号，避免与已重命名的节点重复
+func RestoreRenameCounter(counts map[string]int) {
+	counterLock.Lock()
+	defer counterLock.Unlock()
+
+	for name, count := range counts {
+		counter[name] = count
+	}
+}
+
 func CountryCodeToFlag(code string) string {
 	if len(code) != 2 {
 		return "❓Other"
@@ -0,0 +1,113 @@
+package save
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/beck-8/subs-check/check"
+)
+
+// filterCondRegex 匹配单个筛选条件，例如 country=JP,HK 或 speed>=1024
+var filterCondRegex = regexp.MustCompile(`^([a-z]+)(!=|>=|<=|=|>|<)(.+)$`)
+
+// parseFilter 解析输出配置的筛选表达式
+// 条件之间用空格分隔，全部满足才会保留节点；同一条件的多个值用逗号分隔，满足任意一个即可
+// 支持的字段:
+// - country、type、platform、tag: 支持 = 和 !=
+// - speed(KB/s)、risk(%): 支持 = != > >= < <=
+// 表达式为空时保留全部节点
+func parseFilter(expr string) (func(check.Result) bool, error) {
+	var conds []func(check.Result) bool
+	for _, field := range strings.Fields(expr) {
+		matches := filterCondRegex.FindStringSubmatch(field)
+		if matches == nil {
+			return nil, fmt.Errorf("无法解析筛选条件: %s", field)
+		}
+		key, op, value := matches[1], matches[2], matches[3]
+
+		var cond func(check.Result) bool
+		var err error
+		switch key {
+		case "country":
+			cond, err = stringCond(op, value, func(r check.Result) []string { return []string{r.Country} })
+		case "type":
+			cond, err = stringCond(op, value, func(r check.Result) []string {
+				t, _ := r.Proxy["type"].(string)
+				return []string{t}
+			})
+		case "platform":
+			cond, err = stringCond(op, value, resultPlatforms)
+		case "tag":
+			cond, err = stringCond(op, value, func(r check.Result) []string { return []string{r.SubTag} })
+		case "speed":
+			cond, err = numberCond(op, value, func(r check.Result) (int, bool) { return r.Speed, true })
+		case "risk":
+			cond, err = numberCond(op, value, func(r check.Result) (int, bool) {
+				score, err := strconv.Atoi(strings.TrimSuffix(r.IPRisk, "%"))
+				return score, err == nil
+			})
+		default:
+			return nil, fmt.Errorf("未知的筛选字段: %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("筛选条件 %s 错误: %w", field, err)
+		}
+		conds = append(conds, cond)
+	}
+
+	return func(result check.Result) bool {
+		for _, cond := range conds {
+			if !cond(result) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// stringCond 生成字符串字段的筛选条件，不区分大小写
+func stringCond(op, value string, get func(check.Result) []string) (func(check.Result) bool, error) {
+	if op != "=" && op != "!=" {
+		return nil, fmt.Errorf("不支持的运算符: %s", op)
+	}
+	values := strings.Split(strings.ToLower(value), ",")
+
+	return func(result check.Result) bool {
+		matched := slices.ContainsFunc(get(result), func(s string) bool {
+			return slices.Contains(values, strings.ToLower(s))
+		})
+		return matched == (op == "=")
+	}, nil
+}
+
+// numberCond 生成数值字段的筛选条件，字段缺失时不满足条件
+func numberCond(op, value string, get func(check.Result) (int, bool)) (func(check.Result) bool, error) {
+	want, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("不是有效的数字: %s", value)
+	}
+
+	return func(result check.Result) bool {
+		got, ok := get(result)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "=":
+			return got == want
+		case "!=":
+			return got != want
+		case ">":
+			return got > want
+		case ">=":
+			return got >= want
+		case "<":
+			return got < want
+		default:
+			return got <= want
+		}
+	}, nil
+}
@@ -0,0 +1,58 @@
+package save
+
+import (
+	"testing"
+
+	"github.com/beck-8/subs-check/check"
+)
+
+func TestParseFilter(t *testing.T) {
+	jp := check.Result{Proxy: map[string]any{"type": "vless"}, Country: "JP", Speed: 2048, IPRisk: "15%", Netflix: true, SubTag: "机场A"}
+	us := check.Result{Proxy: map[string]any{"type": "ss"}, Country: "US", Speed: 512}
+
+	tests := []struct {
+		expr    string
+		wantErr bool
+		jp, us  bool
+	}{
+		{expr: "", jp: true, us: true},
+		{expr: "country=JP,HK", jp: true},
+		{expr: "country=jp", jp: true},
+		{expr: "country!=JP", us: true},
+		{expr: "type=ss", us: true},
+		{expr: "platform=netflix", jp: true},
+		{expr: "platform!=netflix", us: true},
+		{expr: "tag=机场A", jp: true},
+		{expr: "speed>=1024", jp: true},
+		{expr: "speed>512", jp: true},
+		{expr: "speed<=512", us: true},
+		{expr: "speed<2048", us: true},
+		{expr: "speed=512", us: true},
+		{expr: "speed!=512", jp: true},
+		{expr: "risk<20", jp: true},                 // 没有风险值的节点不满足数值条件
+		{expr: "country=JP speed>=4096", jp: false}, // 所有条件都要满足
+		{expr: "country=JP,US speed>=512", jp: true, us: true},
+		{expr: "country", wantErr: true},
+		{expr: "latency>1", wantErr: true},
+		{expr: "country>JP", wantErr: true},
+		{expr: "speed>=fast", wantErr: true},
+		{expr: "Country=JP", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := parseFilter(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFilter(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := filter(jp); got != tt.jp {
+				t.Errorf("parseFilter(%q)(JP) = %v, want %v", tt.expr, got, tt.jp)
+			}
+			if got := filter(us); got != tt.us {
+				t.Errorf("parseFilter(%q)(US) = %v, want %v", tt.expr, got, tt.us)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package save
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/save/method"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrPublishBlocked 节点数量骤降，跳过发布
+var ErrPublishBlocked = errors.New("节点数量过少，跳过发布")
+
+// checkPublishGuard 检查本次节点数量是否低于绝对下限或上次发布数量的百分比
+func checkPublishGuard(count int) error {
+	minNodes := config.GlobalConfig.PublishMinNodes
+	if minNodes > 0 && count < minNodes {
+		return fmt.Errorf("%w: 可用节点 %d 少于最低要求 %d", ErrPublishBlocked, count, minNodes)
+	}
+
+	ratio := config.GlobalConfig.PublishMinRatio
+	if ratio <= 0 {
+		return nil
+	}
+	last, err := lastPublishedCount()
+	if err != nil || last == 0 {
+		return nil
+	}
+	if count*100 < last*ratio {
+		return fmt.Errorf("%w: 可用节点 %d 低于上次发布 %d 的 %d%%", ErrPublishBlocked, count, last, ratio)
+	}
+	return nil
+}
+
+// lastPublishedCount 读取本地 node.yaml 中上次发布的节点数量
+func lastPublishedCount() (int, error) {
+	local, err := method.NewLocalSaver()
+	if err != nil {
+		return 0, err
+	}
+	data, err := local.Get("node.yaml")
+	if err != nil {
+		return 0, err
+	}
+	var content struct {
+		Proxies []map[string]any `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return 0, err
+	}
+	return len(content.Proxies), nil
+}
@@ -0,0 +1,48 @@
+package save
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+func TestCheckPublishGuard(t *testing.T) {
+	config.GlobalConfig.OutputDir = t.TempDir()
+	t.Cleanup(func() {
+		config.GlobalConfig.OutputDir = ""
+		config.GlobalConfig.PublishMinNodes = 0
+		config.GlobalConfig.PublishMinRatio = 0
+	})
+	node := "proxies:\n" + strings.Repeat("- {name: a, type: ss}\n", 10)
+	if err := os.WriteFile(filepath.Join(config.GlobalConfig.OutputDir, "node.yaml"), []byte(node), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		minNodes int
+		minRatio int
+		count    int
+		blocked  bool
+	}{
+		{name: "未启用", count: 0},
+		{name: "满足最低数量", minNodes: 5, count: 5},
+		{name: "低于最低数量", minNodes: 5, count: 4, blocked: true},
+		{name: "满足百分比", minRatio: 30, count: 3},
+		{name: "低于百分比", minRatio: 30, count: 2, blocked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.GlobalConfig.PublishMinNodes = tt.minNodes
+			config.GlobalConfig.PublishMinRatio = tt.minRatio
+			err := checkPublishGuard(tt.count)
+			if errors.Is(err, ErrPublishBlocked) != tt.blocked {
+				t.Errorf("checkPublishGuard(%d) error = %v, blocked %v", tt.count, err, tt.blocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package method
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
+)
+
+var (
+	maxRetries    = 3
+	retryInterval = 2 * time.Second
+)
+
+// ErrNotFound 远程文件不存在
+var ErrNotFound = errors.New("文件不存在")
+
+// Backend 存储后端，文件以文件名区分
+type Backend interface {
+	Name() string
+	Put(filename string, data []byte) error
+	Get(filename string) ([]byte, error)
+	List() ([]string, error)
+	Delete(filename string) error
+}
+
+// BatchBackend 支持一次保存多个文件的后端，例如 git 一次运行只产生一个提交
+type BatchBackend interface {
+	Backend
+	PutAll(files []utils.OutputFile, message string) error
+}
+
+// NewBackend 根据保存方法创建后端，连接信息为保存方法中的配置合并顶层配置
+// 远程后端会包装统一的重试和退避逻辑，并使用保存方法的名称区分同类型的多个后端
+func NewBackend(m config.SaveMethod) (Backend, error) {
+	cfg := m.Settings(config.GlobalConfig.BackendConfig)
+	var backend Backend
+	switch m.Type {
+	case "local":
+		return NewLocalSaver()
+	case "r2":
+		if err := ValiR2Config(cfg); err != nil {
+			return nil, fmt.Errorf("R2配置不完整: %v", err)
+		}
+		backend = NewR2Uploader(cfg)
+	case "gist":
+		if err := ValiGistConfig(cfg); err != nil {
+			return nil, fmt.Errorf("Gist配置不完整: %v", err)
+		}
+		backend = NewGistUploader(cfg, m.Label())
+	case "webdav":
+		if err := ValiWebDAVConfig(cfg); err != nil {
+			return nil, fmt.Errorf("WebDAV配置不完整: %v", err)
+		}
+		backend = NewWebDAVUploader(cfg)
+	case "s3":
+		if err := ValiS3Config(cfg); err != nil {
+			return nil, fmt.Errorf("S3配置不完整: %v", err)
+		}
+		s3, err := NewS3Backend(cfg)
+		if err != nil {
+			return nil, err
+		}
+		backend = s3
+	case "sftp":
+		if err := ValiSFTPConfig(cfg); err != nil {
+			return nil, fmt.Errorf("SFTP配置不完整: %v", err)
+		}
+		backend = NewSFTPUploader(cfg)
+	case "ftp":
+		if err := ValiFTPConfig(cfg); err != nil {
+			return nil, fmt.Errorf("FTP配置不完整: %v", err)
+		}
+		backend = NewFTPUploader(cfg)
+	case "git":
+		if err := ValiGitConfig(cfg); err != nil {
+			return nil, fmt.Errorf("Git配置不完整: %v", err)
+		}
+		backend = NewGitUploader(cfg)
+	default:
+		return nil, fmt.Errorf("未知的保存方法: %v", m.Type)
+	}
+	return newRetryBackend(backend, maxRetries, retryInterval, m.Name), nil
+}
+
+// retryBackend 为后端的每个操作增加重试，重试间隔每次翻倍
+type retryBackend struct {
+	Backend
+	name    string // 保存方法的名称，为空时使用后端自身的名称
+	retries int
+	delay   time.Duration
+}
+
+// WithRetry 包装后端，失败时最多尝试 retries 次
+func WithRetry(backend Backend, retries int, delay time.Duration) Backend {
+	return newRetryBackend(backend, retries, delay, "")
+}
+
+func newRetryBackend(backend Backend, retries int, delay time.Duration, name string) Backend {
+	if retries < 1 {
+		retries = 1
+	}
+	retry := &retryBackend{Backend: backend, name: name, retries: retries, delay: delay}
+	if batch, ok := backend.(BatchBackend); ok {
+		return &retryBatchBackend{retryBackend: retry, batch: batch}
+	}
+	return retry
+}
+
+// retryBatchBackend 保留被包装后端的批量保存能力
+type retryBatchBackend struct {
+	*retryBackend
+	batch BatchBackend
+}
+
+// Name 保存方法的名称
+func (r *retryBackend) Name() string {
+	if r.name != "" {
+		return r.name
+	}
+	return r.Backend.Name()
+}
+
+// PutAll 执行带重试的批量上传
+func (r *retryBatchBackend) PutAll(files []utils.OutputFile, message string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("数据为空")
+	}
+	if err := r.retry("上传", func() error { return r.batch.PutAll(files, message) }); err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("%s上传成功", r.Name()), "files", len(files))
+	return nil
+}
+
+// Put 执行带重试的上传
+func (r *retryBackend) Put(filename string, data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("数据为空")
+	}
+	if filename == "" {
+		return fmt.Errorf("文件名不能为空")
+	}
+	if err := r.retry("上传", func() error { return r.Backend.Put(filename, data) }); err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("%s上传成功", r.Name()), "filename", filename)
+	return nil
+}
+
+// Get 执行带重试的下载，文件不存在时不重试
+func (r *retryBackend) Get(filename string) ([]byte, error) {
+	var data []byte
+	err := r.retry("下载", func() error {
+		var err error
+		data, err = r.Backend.Get(filename)
+		return err
+	})
+	return data, err
+}
+
+// List 执行带重试的列举
+func (r *retryBackend) List() ([]string, error) {
+	var names []string
+	err := r.retry("列举文件", func() error {
+		var err error
+		names, err = r.Backend.List()
+		return err
+	})
+	return names, err
+}
+
+// Delete 执行带重试的删除，文件不存在时不重试
+func (r *retryBackend) Delete(filename string) error {
+	return r.retry("删除", func() error { return r.Backend.Delete(filename) })
+}
+
+// retry 按退避间隔重试操作
+func (r *retryBackend) retry(op string, fn func() error) error {
+	var lastErr error
+	delay := r.delay
+
+	for attempt := 0; attempt < r.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrNotFound) {
+			return err
+		}
+		lastErr = err
+		slog.Error(fmt.Sprintf("%s%s失败(尝试 %d/%d) %v", r.Name(), op, attempt+1, r.retries, err))
+	}
+
+	return fmt.Errorf("%s%s失败，已重试%d次: %w", r.Name(), op, r.retries, lastErr)
+}
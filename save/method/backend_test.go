@@ -0,0 +1,120 @@
+package method
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testBackendRoundTrip 对后端执行一轮上传、读取、列举和删除
+func testBackendRoundTrip(t *testing.T, backend Backend) {
+	t.Helper()
+
+	files := map[string]string{
+		"node.yaml":  "proxies: []",
+		"stats.json": `{"nodes":0}`,
+	}
+	for name, content := range files {
+		if err := backend.Put(name, []byte(content)); err != nil {
+			t.Fatalf("Put(%s) error = %v", name, err)
+		}
+	}
+
+	for name, content := range files {
+		data, err := backend.Get(name)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", name, err)
+		}
+		if string(data) != content {
+			t.Errorf("Get(%s) = %q, want %q", name, data, content)
+		}
+	}
+
+	names, err := backend.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	slices.Sort(names)
+	if want := []string{"node.yaml", "stats.json"}; !slices.Equal(names, want) {
+		t.Errorf("List() = %v, want %v", names, want)
+	}
+
+	if err := backend.Delete("stats.json"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := backend.Get("stats.json"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+// flakyBackend 前 failures 次操作失败的后端
+type flakyBackend struct {
+	failures int
+	calls    int
+	err      error
+}
+
+func (f *flakyBackend) Name() string { return "flaky" }
+
+func (f *flakyBackend) Put(string, []byte) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyBackend) Get(string) ([]byte, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, f.err
+	}
+	return []byte("ok"), nil
+}
+
+func (f *flakyBackend) List() ([]string, error) { return nil, nil }
+
+func (f *flakyBackend) Delete(string) error { return nil }
+
+func TestWithRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "成功", failures: 0, err: errors.New("boom"), wantCalls: 1},
+		{name: "重试后成功", failures: 2, err: errors.New("boom"), wantCalls: 3},
+		{name: "重试耗尽", failures: 5, err: errors.New("boom"), wantCalls: 3, wantErr: true},
+		{name: "文件不存在不重试", failures: 5, err: ErrNotFound, wantCalls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaky := &flakyBackend{failures: tt.failures, err: tt.err}
+			backend := WithRetry(flaky, 3, time.Millisecond)
+			_, err := backend.Get("node.yaml")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if flaky.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", flaky.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWithRetryPutValidate(t *testing.T) {
+	flaky := &flakyBackend{}
+	backend := WithRetry(flaky, 3, time.Millisecond)
+	if err := backend.Put("node.yaml", nil); err == nil {
+		t.Errorf("Put() with empty data should fail")
+	}
+	if err := backend.Put("", []byte("x")); err == nil {
+		t.Errorf("Put() with empty filename should fail")
+	}
+	if flaky.calls != 0 {
+		t.Errorf("calls = %d, want 0", flaky.calls)
+	}
+}
@@ -6,18 +6,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 
-	"log/slog"
-
 	"github.com/beck-8/subs-check/config"
 )
 
-const (
-	maxRetries    = 3
-	retryInterval = 2 * time.Second
-)
-
 // KVPayload 定义上传到R2的数据结构
 type KVPayload struct {
 	Filename string `json:"filename"`
@@ -32,42 +26,35 @@ type R2Uploader struct {
 }
 
 // NewR2Uploader 创建新的R2上传器
-func NewR2Uploader() *R2Uploader {
+func NewR2Uploader(cfg config.BackendConfig) *R2Uploader {
 	return &R2Uploader{
 		client:    &http.Client{Timeout: 30 * time.Second},
-		workerURL: config.GlobalConfig.WorkerURL,
-		token:     config.GlobalConfig.WorkerToken,
+		workerURL: cfg.WorkerURL,
+		token:     cfg.WorkerToken,
 	}
 }
 
-// UploadToR2Storage 上传数据到R2存储的入口函数
-func UploadToR2Storage(yamlData []byte, filename string) error {
-	uploader := NewR2Uploader()
-	return uploader.Upload(yamlData, filename)
-}
-
 // valiR2Config 验证R2配置
-func ValiR2Config() error {
-	if config.GlobalConfig.WorkerURL == "" {
+func ValiR2Config(cfg config.BackendConfig) error {
+	if cfg.WorkerURL == "" {
 		return fmt.Errorf("worker url未配置")
 	}
-	if config.GlobalConfig.WorkerToken == "" {
+	if cfg.WorkerToken == "" {
 		return fmt.Errorf("worker token未配置")
 	}
 	return nil
 }
 
-// Upload 执行上传操作
-func (r *R2Uploader) Upload(yamlData []byte, filename string) error {
-	// 验证输入
-	if err := r.validateInput(yamlData, filename); err != nil {
-		return err
-	}
+// Name 后端名称
+func (r *R2Uploader) Name() string {
+	return "R2"
+}
 
-	// 准备请求数据
+// Put 执行单次上传
+func (r *R2Uploader) Put(filename string, data []byte) error {
 	payload := KVPayload{
 		Filename: filename,
-		Value:    string(yamlData),
+		Value:    string(data),
 	}
 
 	jsonData, err := json.Marshal(payload)
@@ -75,81 +62,67 @@ func (r *R2Uploader) Upload(yamlData []byte, filename string) error {
 		return fmt.Errorf("JSON编码失败: %w", err)
 	}
 
-	// 执行带重试的上传
-	return r.uploadWithRetry(jsonData, filename)
+	_, err = r.do("POST", nil, jsonData)
+	return err
 }
 
-// validateInput 验证输入参数
-func (r *R2Uploader) validateInput(yamlData []byte, filename string) error {
-	if len(yamlData) == 0 {
-		return fmt.Errorf("yaml数据为空")
+// Get 下载单个文件
+func (r *R2Uploader) Get(filename string) ([]byte, error) {
+	return r.do("GET", url.Values{"filename": {filename}}, nil)
+}
+
+// List 列出存储中的文件，需要 worker 支持 action=list
+func (r *R2Uploader) List() ([]string, error) {
+	body, err := r.do("GET", url.Values{"action": {"list"}}, nil)
+	if err != nil {
+		return nil, err
 	}
-	if filename == "" {
-		return fmt.Errorf("filename不能为空")
+
+	var result struct {
+		Keys []string `json:"keys"`
 	}
-	if r.workerURL == "" || r.token == "" {
-		return fmt.Errorf("Worker配置不完整")
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析文件列表失败: %w", err)
 	}
-	return nil
+	return result.Keys, nil
 }
 
-// uploadWithRetry 带重试机制的上传
-func (r *R2Uploader) uploadWithRetry(jsonData []byte, filename string) error {
-	var lastErr error
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if err := r.doUpload(jsonData); err != nil {
-			lastErr = err
-			slog.Error(fmt.Sprintf("R2上传失败(尝试 %d/%d) %v", attempt+1, maxRetries, err))
-			time.Sleep(retryInterval)
-			continue
-		}
-		slog.Info("R2上传成功", "filename", filename)
-		return nil
-	}
-
-	return fmt.Errorf("上传失败，已重试%d次: %w", maxRetries, lastErr)
+// Delete 删除单个文件，需要 worker 支持 DELETE 请求
+func (r *R2Uploader) Delete(filename string) error {
+	_, err := r.do("DELETE", url.Values{"filename": {filename}}, nil)
+	return err
 }
 
-// doUpload 执行单次上传
-func (r *R2Uploader) doUpload(jsonData []byte) error {
-	// 创建请求
-	req, err := r.createRequest(jsonData)
+// do 请求 worker 的 storage 接口
+func (r *R2Uploader) do(method string, query url.Values, body []byte) ([]byte, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("token", r.token)
+
+	req, err := http.NewRequest(method, r.workerURL+"/storage?"+query.Encode(), bytes.NewReader(body))
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
 
-	// 发送请求
 	resp, err := r.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("发送请求失败: %w", err)
+		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// 检查响应
-	return r.checkResponse(resp)
-}
-
-// createRequest 创建HTTP请求
-func (r *R2Uploader) createRequest(jsonData []byte) (*http.Request, error) {
-	url := fmt.Sprintf("%s/storage?token=%s", r.workerURL, r.token)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
+		return nil, fmt.Errorf("读取响应失败(状态码: %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	return req, nil
-}
-
-// checkResponse 检查响应结果
-func (r *R2Uploader) checkResponse(resp *http.Response) error {
 	if resp.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("读取响应失败(状态码: %d): %w", resp.StatusCode, err)
-		}
-		return fmt.Errorf("上传失败(状态码: %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("请求失败(状态码: %d): %s", resp.StatusCode, string(respBody))
 	}
-	return nil
+	return respBody, nil
 }
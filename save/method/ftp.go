@@ -0,0 +1,177 @@
+package method
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/jlaffaye/ftp"
+)
+
+// FTPUploader 处理 FTP/FTPS 上传的结构体
+type FTPUploader struct {
+	host          string
+	username      string
+	password      string
+	dir           string
+	tlsMode       string
+	skipTLSVerify bool
+}
+
+// NewFTPUploader 创建新的 FTP 上传器
+func NewFTPUploader(cfg config.BackendConfig) *FTPUploader {
+	return &FTPUploader{
+		host:          cfg.FTPHost,
+		username:      cfg.FTPUsername,
+		password:      cfg.FTPPassword,
+		dir:           cfg.FTPDir,
+		tlsMode:       cfg.FTPTLS,
+		skipTLSVerify: cfg.FTPSkipTLSVerify,
+	}
+}
+
+// ValiFTPConfig 验证FTP配置
+func ValiFTPConfig(cfg config.BackendConfig) error {
+	if cfg.FTPHost == "" {
+		return fmt.Errorf("ftp 地址未配置")
+	}
+	if cfg.FTPUsername == "" {
+		return fmt.Errorf("ftp 用户名未配置")
+	}
+	switch cfg.FTPTLS {
+	case "", "explicit", "implicit":
+	default:
+		return fmt.Errorf("ftp-tls 只支持 explicit 或 implicit: %s", cfg.FTPTLS)
+	}
+	return nil
+}
+
+// Name 后端名称
+func (f *FTPUploader) Name() string {
+	return "ftp"
+}
+
+// Put 先上传到临时文件再重命名，避免客户端读到不完整的文件
+func (f *FTPUploader) Put(filename string, data []byte) error {
+	return f.withConn(func(conn *ftp.ServerConn) error {
+		f.makeDirs(conn)
+
+		target := path.Join(f.dir, filename)
+		temp := path.Join(f.dir, "."+filename+".tmp")
+		if err := conn.Stor(temp, bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("上传文件失败: %w", err)
+		}
+		// 部分服务器不允许重命名覆盖已有文件，失败时先删除旧文件
+		if err := conn.Rename(temp, target); err != nil {
+			conn.Delete(target)
+			if err := conn.Rename(temp, target); err != nil {
+				conn.Delete(temp)
+				return fmt.Errorf("重命名文件失败: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Get 下载单个文件
+func (f *FTPUploader) Get(filename string) ([]byte, error) {
+	var data []byte
+	err := f.withConn(func(conn *ftp.ServerConn) error {
+		resp, err := conn.Retr(path.Join(f.dir, filename))
+		if err != nil {
+			return convertFTPError(err)
+		}
+		defer resp.Close()
+		data, err = io.ReadAll(resp)
+		return err
+	})
+	return data, err
+}
+
+// List 列出远程目录中的文件
+func (f *FTPUploader) List() ([]string, error) {
+	var names []string
+	err := f.withConn(func(conn *ftp.ServerConn) error {
+		dir := f.dir
+		if dir == "" {
+			dir = "."
+		}
+		entries, err := conn.List(dir)
+		if err != nil {
+			if errors.Is(convertFTPError(err), ErrNotFound) {
+				return nil
+			}
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Type == ftp.EntryTypeFile {
+				names = append(names, path.Base(entry.Name))
+			}
+		}
+		return nil
+	})
+	return names, err
+}
+
+// Delete 删除单个文件
+func (f *FTPUploader) Delete(filename string) error {
+	return f.withConn(func(conn *ftp.ServerConn) error {
+		return convertFTPError(conn.Delete(path.Join(f.dir, filename)))
+	})
+}
+
+// makeDirs 逐级创建远程目录，目录已存在时服务器返回的错误忽略
+func (f *FTPUploader) makeDirs(conn *ftp.ServerConn) {
+	current := ""
+	if strings.HasPrefix(f.dir, "/") {
+		current = "/"
+	}
+	for _, part := range strings.Split(strings.Trim(f.dir, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		current = path.Join(current, part)
+		conn.MakeDir(current)
+	}
+}
+
+// withConn 建立 FTP 连接、登录并执行操作
+func (f *FTPUploader) withConn(fn func(conn *ftp.ServerConn) error) error {
+	options := []ftp.DialOption{ftp.DialWithTimeout(30 * time.Second)}
+	host, _, _ := net.SplitHostPort(f.host)
+	tlsConfig := &tls.Config{ServerName: host, InsecureSkipVerify: f.skipTLSVerify}
+	switch f.tlsMode {
+	case "explicit":
+		options = append(options, ftp.DialWithExplicitTLS(tlsConfig))
+	case "implicit":
+		options = append(options, ftp.DialWithTLS(tlsConfig))
+	}
+
+	conn, err := ftp.Dial(f.host, options...)
+	if err != nil {
+		return fmt.Errorf("连接 ftp 服务器失败: %w", err)
+	}
+	defer conn.Quit()
+
+	if err := conn.Login(f.username, f.password); err != nil {
+		return fmt.Errorf("ftp 登录失败: %w", err)
+	}
+	return fn(conn)
+}
+
+// convertFTPError 将 550 文件不可用转换为 ErrNotFound
+func convertFTPError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code == ftp.StatusFileUnavailable {
+		return ErrNotFound
+	}
+	return err
+}
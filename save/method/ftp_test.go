@@ -0,0 +1,189 @@
+package method
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+// fakeFTP 内存中的 FTP 服务，只实现上传、下载、列举、重命名和删除需要的命令
+type fakeFTP struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	dirs  map[string]bool
+}
+
+// newFakeFTP 启动 FTP 服务并返回监听地址
+func newFakeFTP(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeFTP{files: make(map[string][]byte), dirs: map[string]bool{"/": true}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func (s *fakeFTP) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(format string, args ...any) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	var data net.Listener
+	defer func() {
+		if data != nil {
+			data.Close()
+		}
+	}()
+	// transfer 接受数据连接，执行完成后回复 226
+	transfer := func(fn func(conn net.Conn)) {
+		if data == nil {
+			reply("425 Use EPSV first")
+			return
+		}
+		reply("150 Opening data connection")
+		dataConn, err := data.Accept()
+		data.Close()
+		data = nil
+		if err != nil {
+			return
+		}
+		fn(dataConn)
+		dataConn.Close()
+		reply("226 Transfer complete")
+	}
+
+	var renameFrom string
+	reply("220 fake ftp")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		name := path.Join("/", arg)
+
+		switch strings.ToUpper(cmd) {
+		case "FEAT":
+			reply("211-Features:\r\n MLST type*;size*;\r\n211 End")
+		case "USER":
+			reply("331 Password required")
+		case "PASS":
+			if arg != "pass" {
+				reply("530 Login incorrect")
+				continue
+			}
+			reply("230 Logged in")
+		case "TYPE", "OPTS":
+			reply("200 OK")
+		case "EPSV":
+			data, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				reply("425 Cannot open data connection")
+				continue
+			}
+			reply("229 Entering Extended Passive Mode (|||%d|)", data.Addr().(*net.TCPAddr).Port)
+		case "MKD":
+			s.mu.Lock()
+			exists := s.dirs[name]
+			s.dirs[name] = true
+			s.mu.Unlock()
+			if exists {
+				reply("550 Directory exists")
+				continue
+			}
+			reply("257 Created")
+		case "STOR":
+			transfer(func(conn net.Conn) {
+				content, _ := io.ReadAll(conn)
+				s.mu.Lock()
+				s.files[name] = content
+				s.mu.Unlock()
+			})
+		case "RETR":
+			s.mu.Lock()
+			content, ok := s.files[name]
+			s.mu.Unlock()
+			if !ok {
+				reply("550 File not found")
+				continue
+			}
+			transfer(func(conn net.Conn) { conn.Write(content) })
+		case "MLSD":
+			s.mu.Lock()
+			var entries []string
+			for file, content := range s.files {
+				if path.Dir(file) == name {
+					entries = append(entries, fmt.Sprintf("type=file;size=%d; %s\r\n", len(content), path.Base(file)))
+				}
+			}
+			s.mu.Unlock()
+			transfer(func(conn net.Conn) { io.WriteString(conn, strings.Join(entries, "")) })
+		case "RNFR":
+			s.mu.Lock()
+			_, ok := s.files[name]
+			s.mu.Unlock()
+			if !ok {
+				reply("550 File not found")
+				continue
+			}
+			renameFrom = name
+			reply("350 Ready for RNTO")
+		case "RNTO":
+			s.mu.Lock()
+			s.files[name] = s.files[renameFrom]
+			delete(s.files, renameFrom)
+			s.mu.Unlock()
+			reply("250 Renamed")
+		case "DELE":
+			s.mu.Lock()
+			_, ok := s.files[name]
+			delete(s.files, name)
+			s.mu.Unlock()
+			if !ok {
+				reply("550 File not found")
+				continue
+			}
+			reply("250 Deleted")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestFTPBackend(t *testing.T) {
+	config.GlobalConfig.FTPHost = newFakeFTP(t)
+	config.GlobalConfig.FTPUsername = "user"
+	config.GlobalConfig.FTPPassword = "pass"
+	config.GlobalConfig.FTPDir = "/data/subs"
+
+	testBackendRoundTrip(t, NewFTPUploader(config.GlobalConfig.BackendConfig))
+
+	config.GlobalConfig.FTPPassword = "wrong"
+	if err := NewFTPUploader(config.GlobalConfig.BackendConfig).Put("node.yaml", []byte("x")); err == nil {
+		t.Errorf("Put() with wrong password should fail")
+	}
+}
@@ -3,148 +3,259 @@ package method
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
-	"log/slog"
-
 	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
 )
 
-var (
-	gistAPIURL     = "https://api.github.com/gists"
-	gistMaxRetries = 3
-	gistRetryDelay = 2 * time.Second
-)
+var gistAPIURL = "https://api.github.com/gists"
+
+// gistStateMu 保护自动创建的 gist id 的读写
+var gistStateMu sync.Mutex
+
+// GistArtifactNames 返回本程序生成的所有文件名，由 save 包设置
+// PutAll 只删除其中本次没有生成的文件，不影响用户放在 Gist 中的其他文件
+var GistArtifactNames func() []string
 
 // GistFile 表示 Gist 文件的结构
 type GistFile struct {
-	Content string `json:"content"`
+	Content   string `json:"content"`
+	Truncated bool   `json:"truncated,omitempty"`
+	RawURL    string `json:"raw_url,omitempty"`
 }
 
-// GistPayload 表示创建 Gist 的请求结构
+// GistPayload 表示创建和更新 Gist 的请求结构，文件值为 nil 表示删除
 type GistPayload struct {
-	Description string              `json:"description"`
-	Public      bool                `json:"public"`
-	Files       map[string]GistFile `json:"files"`
+	ID          string               `json:"id,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Public      bool                 `json:"public,omitempty"`
+	Files       map[string]*GistFile `json:"files"`
 }
 
 // GistUploader 处理 GitHub Gist 上传的结构体
 type GistUploader struct {
 	client   *http.Client
+	apiURL   string
 	token    string
 	id       string
 	isPublic bool
+	state    string // 自动创建的 gist id 的保存路径
 }
 
-// NewGistUploader 创建新的 Gist 上传器
-func NewGistUploader() *GistUploader {
-	if config.GlobalConfig.GithubAPIMirror != "" {
-		gistAPIURL = config.GlobalConfig.GithubAPIMirror + "/gists"
+// NewGistUploader 创建新的 Gist 上传器，name 为保存方法的名称
+// 没有配置 github-gist-id 时使用之前自动创建的 gist，重启后不会再次创建
+func NewGistUploader(cfg config.BackendConfig, name string) *GistUploader {
+	apiURL := gistAPIURL
+	if cfg.GithubAPIMirror != "" {
+		apiURL = cfg.GithubAPIMirror + "/gists"
 	}
-	return &GistUploader{
+	g := &GistUploader{
 		client:   &http.Client{Timeout: 30 * time.Second},
-		token:    config.GlobalConfig.GithubToken,
-		id:       config.GlobalConfig.GithubGistID,
-		isPublic: false,
+		apiURL:   apiURL,
+		token:    cfg.GithubToken,
+		id:       cfg.GithubGistID,
+		isPublic: cfg.GithubGistPublic,
+		state:    gistStatePath(name),
+	}
+	if g.id == "" {
+		g.id = g.loadCreatedID()
 	}
+	return g
 }
 
-// UploadToGist 上传数据到 Gist 的入口函数
-func UploadToGist(yamlData []byte, filename string) error {
-	uploader := NewGistUploader()
-	return uploader.Upload(yamlData, filename)
+// gistStatePath 自动创建的 gist id 保存在 config/cache 中，多个 gist 保存方法按名称区分
+func gistStatePath(name string) string {
+	if name == "" {
+		name = "gist"
+	}
+	return filepath.Join(utils.GetConfigDir(), "cache", "gist-id-"+filepath.Base(name))
 }
 
-// ValiGistConfig 验证Gist配置
-func ValiGistConfig() error {
-	if config.GlobalConfig.GithubToken == "" {
-		return fmt.Errorf("github token未配置")
+// loadCreatedID 读取之前自动创建的 gist id，不存在时返回空
+func (g *GistUploader) loadCreatedID() string {
+	gistStateMu.Lock()
+	defer gistStateMu.Unlock()
+	data, err := os.ReadFile(g.state)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn(fmt.Sprintf("读取自动创建的 gist id 失败: %v", err))
+		}
+		return ""
 	}
-	if config.GlobalConfig.GithubGistID == "" {
-		return fmt.Errorf("gist id未配置")
+	return strings.TrimSpace(string(data))
+}
+
+// saveCreatedID 保存自动创建的 gist id
+func (g *GistUploader) saveCreatedID(id string) error {
+	gistStateMu.Lock()
+	defer gistStateMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(g.state), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(g.state, []byte(id+"\n"))
+}
+
+// ValiGistConfig 验证Gist配置，gist id 为空时会自动创建
+func ValiGistConfig(cfg config.BackendConfig) error {
+	if cfg.GithubToken == "" {
+		return fmt.Errorf("github token未配置")
 	}
 	return nil
 }
 
-// Upload 执行上传操作
-func (g *GistUploader) Upload(yamlData []byte, filename string) error {
-	if err := g.validateInput(yamlData, filename); err != nil {
-		return err
+// Name 后端名称
+func (g *GistUploader) Name() string {
+	return "gist"
+}
+
+// Put 上传单个文件
+func (g *GistUploader) Put(filename string, data []byte) error {
+	files := map[string]*GistFile{filename: {Content: string(data)}}
+	if g.id == "" {
+		return g.create(files, "")
 	}
+	return g.update(files, "")
+}
 
-	payload := GistPayload{
-		Description: "Configuration",
-		Public:      g.isPublic,
-		Files: map[string]GistFile{
-			filename: {
-				Content: string(yamlData),
-			},
-		},
+// PutAll 在一次请求中更新所有文件，并删除本程序之前生成、本次没有生成的旧文件
+func (g *GistUploader) PutAll(files []utils.OutputFile, message string) error {
+	payload := make(map[string]*GistFile, len(files))
+	for _, file := range files {
+		payload[file.Name] = &GistFile{Content: string(file.Data)}
+	}
+	if g.id == "" {
+		return g.create(payload, message)
 	}
 
-	jsonData, err := json.Marshal(payload)
+	existing, err := g.files()
 	if err != nil {
-		return fmt.Errorf("JSON编码失败: %w", err)
+		return err
 	}
+	var artifacts []string
+	if GistArtifactNames != nil {
+		artifacts = GistArtifactNames()
+	}
+	for name := range existing {
+		if _, ok := payload[name]; !ok && slices.Contains(artifacts, name) {
+			payload[name] = nil
+		}
+	}
+	return g.update(payload, message)
+}
 
-	return g.uploadWithRetry(jsonData, filename)
+// Delete 删除单个文件
+func (g *GistUploader) Delete(filename string) error {
+	if g.id == "" {
+		return ErrNotFound
+	}
+	return g.update(map[string]*GistFile{filename: nil}, "")
 }
 
-// validateInput 验证输入参数
-func (g *GistUploader) validateInput(yamlData []byte, filename string) error {
-	if len(yamlData) == 0 {
-		return fmt.Errorf("yaml数据为空")
+// Get 下载单个文件，内容被截断时从 raw_url 获取完整内容
+func (g *GistUploader) Get(filename string) ([]byte, error) {
+	if g.id == "" {
+		return nil, ErrNotFound
+	}
+	files, err := g.files()
+	if err != nil {
+		return nil, err
 	}
-	if filename == "" {
-		return fmt.Errorf("文件名不能为空")
+	file, ok := files[filename]
+	if !ok || file == nil {
+		return nil, ErrNotFound
 	}
-	if g.token == "" {
-		return fmt.Errorf("github token未配置")
+	if !file.Truncated {
+		return []byte(file.Content), nil
 	}
-	return nil
+	return g.request("GET", file.RawURL, nil)
 }
 
-// uploadWithRetry 带重试机制的上传
-func (g *GistUploader) uploadWithRetry(jsonData []byte, filename string) error {
-	var lastErr error
-
-	for attempt := 0; attempt < gistMaxRetries; attempt++ {
-		if err := g.doUpload(jsonData); err != nil {
-			lastErr = err
-			slog.Error(fmt.Sprintf("gist上传失败(尝试 %d/%d) %v", attempt+1, gistMaxRetries, err))
-			time.Sleep(gistRetryDelay)
-			continue
-		}
-		slog.Info("gist上传成功", "filename", filename)
-		return nil
+// List 列出 Gist 中的文件
+func (g *GistUploader) List() ([]string, error) {
+	if g.id == "" {
+		return nil, nil
+	}
+	files, err := g.files()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names, nil
+}
 
-	return fmt.Errorf("gist上传失败，已重试%d次: %w", gistMaxRetries, lastErr)
+// files 获取 Gist 的文件列表
+func (g *GistUploader) files() (map[string]*GistFile, error) {
+	body, err := g.request("GET", g.apiURL+"/"+g.id, nil)
+	if err != nil {
+		return nil, err
+	}
+	var gist GistPayload
+	if err := json.Unmarshal(body, &gist); err != nil {
+		return nil, fmt.Errorf("解析 gist 失败: %w", err)
+	}
+	return gist.Files, nil
 }
 
-// doUpload 执行单次上传
-func (g *GistUploader) doUpload(jsonData []byte) error {
-	req, err := g.createRequest(jsonData)
+// update 更新 Gist 中的文件
+func (g *GistUploader) update(files map[string]*GistFile, description string) error {
+	jsonData, err := json.Marshal(GistPayload{Description: description, Files: files})
 	if err != nil {
-		return err
+		return fmt.Errorf("JSON编码失败: %w", err)
 	}
+	_, err = g.request("PATCH", g.apiURL+"/"+g.id, jsonData)
+	return err
+}
 
-	resp, err := g.client.Do(req)
+// create 创建新的 Gist，id 保存在 config/cache 中，之后的保存都使用这个 Gist
+// 公开或私密只能在创建时指定，之后修改 github-gist-public 不会影响已有的 Gist
+func (g *GistUploader) create(files map[string]*GistFile, description string) error {
+	if description == "" {
+		description = "subs-check"
+	}
+	jsonData, err := json.Marshal(GistPayload{Description: description, Public: g.isPublic, Files: files})
 	if err != nil {
-		return fmt.Errorf("发送请求失败: %w", err)
+		return fmt.Errorf("JSON编码失败: %w", err)
+	}
+	body, err := g.request("POST", g.apiURL, jsonData)
+	if err != nil {
+		return fmt.Errorf("创建 gist 失败: %w", err)
 	}
-	defer resp.Body.Close()
 
-	return g.checkResponse(resp)
+	var gist GistPayload
+	if err := json.Unmarshal(body, &gist); err != nil {
+		return fmt.Errorf("解析 gist 失败: %w", err)
+	}
+	if gist.ID == "" {
+		return fmt.Errorf("创建 gist 失败: 响应中没有 gist id")
+	}
+	g.id = gist.ID
+	if err := g.saveCreatedID(gist.ID); err != nil {
+		slog.Warn(fmt.Sprintf("已创建 gist，但保存 gist id 失败: %v，请将 github-gist-id 设置为 %s，否则重启后会再次创建", err, gist.ID), "public", g.isPublic)
+		return nil
+	}
+	slog.Info(fmt.Sprintf("已创建 gist: %s", gist.ID), "public", g.isPublic)
+	return nil
 }
 
-// createRequest 创建HTTP请求
-func (g *GistUploader) createRequest(jsonData []byte) (*http.Request, error) {
-	url := gistAPIURL + "/" + g.id
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+// request 发送 GitHub API 请求
+func (g *GistUploader) request(method, url string, jsonData []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -152,17 +263,22 @@ func (g *GistUploader) createRequest(jsonData []byte) (*http.Request, error) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+g.token)
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	return req, nil
-}
 
-// checkResponse 检查响应结果
-func (g *GistUploader) checkResponse(resp *http.Response) error {
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败(状态码: %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("读取响应失败(状态码: %d): %w", resp.StatusCode, err)
-		}
-		return fmt.Errorf("上传失败(状态码: %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("请求失败(状态码: %d): %s", resp.StatusCode, string(body))
 	}
-	return nil
+	return body, nil
 }
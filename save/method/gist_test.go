@@ -0,0 +1,148 @@
+package method
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
+)
+
+// fakeGist 模拟 GitHub Gist API，创建的 gist id 固定为 abc
+type fakeGist struct {
+	mu      sync.Mutex
+	created bool
+	public  bool
+	patches int
+	files   map[string]*GistFile
+}
+
+func newFakeGist(t *testing.T) (*fakeGist, *httptest.Server) {
+	gist := &fakeGist{files: make(map[string]*GistFile)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		gist.mu.Lock()
+		defer gist.mu.Unlock()
+		var payload GistPayload
+		if r.Method != "GET" {
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/gists":
+			gist.created = true
+			gist.public = payload.Public
+			gist.files = payload.Files
+			w.WriteHeader(http.StatusCreated)
+		case r.URL.Path != "/gists/abc" || !gist.created:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case r.Method == "PATCH":
+			gist.patches++
+			for name, file := range payload.Files {
+				if file == nil {
+					delete(gist.files, name)
+					continue
+				}
+				gist.files[name] = file
+			}
+		case r.Method != "GET":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		json.NewEncoder(w).Encode(GistPayload{ID: "abc", Public: gist.public, Files: gist.files})
+	}))
+	t.Cleanup(server.Close)
+	return gist, server
+}
+
+func setGistConfig(t *testing.T, server *httptest.Server, id string) {
+	config.GlobalConfig.GithubAPIMirror = server.URL
+	config.GlobalConfig.GithubToken = "token"
+	config.GlobalConfig.GithubGistID = id
+	t.Cleanup(func() {
+		config.GlobalConfig.GithubAPIMirror = ""
+		config.GlobalConfig.GithubGistID = ""
+		config.GlobalConfig.GithubGistPublic = false
+	})
+}
+
+func TestGistBackend(t *testing.T) {
+	gist, server := newFakeGist(t)
+	gist.created = true
+	setGistConfig(t, server, "abc")
+
+	testBackendRoundTrip(t, NewGistUploader(config.GlobalConfig.BackendConfig, "gist"))
+}
+
+func TestGistCreateAndPutAll(t *testing.T) {
+	gist, server := newFakeGist(t)
+	setGistConfig(t, server, "")
+	config.GlobalConfig.GithubGistPublic = true
+
+	state := gistStatePath("gist")
+	os.Remove(state)
+	t.Cleanup(func() {
+		os.Remove(state)
+		GistArtifactNames = nil
+	})
+	GistArtifactNames = func() []string { return []string{"node.yaml", "stats.json", "old.yaml"} }
+
+	// 没有配置 gist id 时自动创建，id 保存到 config/cache 中，重启后继续使用同一个 gist
+	first := []utils.OutputFile{
+		{Name: "node.yaml", Data: []byte("a")},
+		{Name: "old.yaml", Data: []byte("a")},
+		{Name: "notes.md", Data: []byte("a")},
+	}
+	if err := NewGistUploader(config.GlobalConfig.BackendConfig, "gist").PutAll(first, "first"); err != nil {
+		t.Fatalf("PutAll() error = %v", err)
+	}
+	if !gist.created || !gist.public {
+		t.Errorf("gist created = %v, public = %v", gist.created, gist.public)
+	}
+	if config.GlobalConfig.GithubGistID != "" {
+		t.Errorf("GithubGistID = %q, 不应修改配置", config.GlobalConfig.GithubGistID)
+	}
+	if data, err := os.ReadFile(state); err != nil || strings.TrimSpace(string(data)) != "abc" {
+		t.Errorf("gist id 状态文件 = %q, %v, want abc", data, err)
+	}
+	if id := NewGistUploader(config.GlobalConfig.BackendConfig, "gist").id; id != "abc" {
+		t.Errorf("NewGistUploader().id = %q, want abc", id)
+	}
+	// 不同名称的 gist 保存方法不共用自动创建的 gist
+	if id := NewGistUploader(config.GlobalConfig.BackendConfig, "other").id; id != "" {
+		t.Errorf("NewGistUploader(other).id = %q, want empty", id)
+	}
+
+	// 再次保存时一次更新所有文件，只删除本程序生成、本次没有生成的文件
+	second := []utils.OutputFile{
+		{Name: "node.yaml", Data: []byte("b")},
+		{Name: "stats.json", Data: []byte("b")},
+	}
+	if err := NewGistUploader(config.GlobalConfig.BackendConfig, "gist").PutAll(second, "second"); err != nil {
+		t.Fatalf("PutAll() error = %v", err)
+	}
+	if gist.patches != 1 {
+		t.Errorf("patches = %d, want 1", gist.patches)
+	}
+	names, err := NewGistUploader(config.GlobalConfig.BackendConfig, "gist").List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if want := []string{"node.yaml", "notes.md", "stats.json"}; !slices.Equal(names, want) {
+		t.Errorf("List() = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,315 @@
+package method
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
+)
+
+const (
+	defaultGitBranch      = "main"
+	defaultGitAuthorName  = "subs-check"
+	defaultGitAuthorEmail = "subs-check@localhost"
+)
+
+// GitUploader 将输出文件提交到 git 仓库的指定分支，依赖系统中的 git 命令
+type GitUploader struct {
+	remote      string
+	branch      string
+	token       string
+	authHeader  string // token 认证的请求头，通过环境变量传给 git，不出现在命令行参数中
+	sshKey      string
+	authorName  string
+	authorEmail string
+	squash      bool
+
+	mu    sync.Mutex
+	files map[string][]byte // 远程分支的文件，List 和 Get 共用一次克隆，提交后失效
+}
+
+// NewGitUploader 创建新的 git 上传器
+func NewGitUploader(cfg config.BackendConfig) *GitUploader {
+	g := &GitUploader{
+		remote:      cfg.GitURL,
+		branch:      cfg.GitBranch,
+		token:       cfg.GitToken,
+		sshKey:      cfg.GitSSHKey,
+		authorName:  cfg.GitAuthorName,
+		authorEmail: cfg.GitAuthorEmail,
+		squash:      cfg.GitSquash,
+	}
+	if g.branch == "" {
+		g.branch = defaultGitBranch
+	}
+	if g.authorName == "" {
+		g.authorName = defaultGitAuthorName
+	}
+	if g.authorEmail == "" {
+		g.authorEmail = defaultGitAuthorEmail
+	}
+
+	// HTTPS 仓库使用 token 认证，token 放在请求头中，避免出现在 ps 可见的远程地址里
+	if u, err := url.Parse(g.remote); err == nil && (u.Scheme == "https" || u.Scheme == "http") && g.token != "" {
+		username := cfg.GitUsername
+		if username == "" {
+			username = "git"
+		}
+		g.authHeader = "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+g.token))
+	}
+	return g
+}
+
+// ValiGitConfig 验证git配置
+func ValiGitConfig(cfg config.BackendConfig) error {
+	if cfg.GitURL == "" {
+		return fmt.Errorf("git url未配置")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("未找到git命令: %w", err)
+	}
+	if cfg.GitSSHKey != "" {
+		if info, err := os.Stat(cfg.GitSSHKey); err != nil || !info.Mode().IsRegular() {
+			return fmt.Errorf("git ssh 私钥文件不存在: %s", cfg.GitSSHKey)
+		}
+	}
+	return nil
+}
+
+// Name 后端名称
+func (g *GitUploader) Name() string {
+	return "git"
+}
+
+// Put 提交单个文件
+func (g *GitUploader) Put(filename string, data []byte) error {
+	return g.PutAll([]utils.OutputFile{{Name: filename, Data: data}}, "更新 "+filename)
+}
+
+// PutAll 将所有文件作为一次提交推送到远程分支
+// 开启 squash 时每次都创建不带历史的新提交并强制推送，分支只保留最新一次结果
+func (g *GitUploader) PutAll(files []utils.OutputFile, message string) error {
+	g.invalidate()
+	return g.withRepo(!g.squash, func(repo string, fetched bool) error {
+		for _, file := range files {
+			if filepath.Base(file.Name) != file.Name {
+				return fmt.Errorf("filename包含非法字符: %s", file.Name)
+			}
+			if err := os.WriteFile(filepath.Join(repo, file.Name), file.Data, 0644); err != nil {
+				return fmt.Errorf("写入文件失败 [%s]: %w", file.Name, err)
+			}
+		}
+		return g.commitAndPush(repo, message, g.squash)
+	})
+}
+
+// Get 读取远程分支中的文件
+func (g *GitUploader) Get(filename string) ([]byte, error) {
+	files, err := g.remoteFiles()
+	if err != nil {
+		return nil, err
+	}
+	data, ok := files[filepath.Base(filename)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+// List 列出远程分支中的文件
+func (g *GitUploader) List() ([]string, error) {
+	files, err := g.remoteFiles()
+	if err != nil {
+		return nil, err
+	}
+	return slices.Sorted(maps.Keys(files)), nil
+}
+
+// remoteFiles 克隆一次远程分支并读取所有文件，恢复输出时 List 和多次 Get 只需要一次克隆
+func (g *GitUploader) remoteFiles() (map[string][]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.files != nil {
+		return g.files, nil
+	}
+
+	files := make(map[string][]byte)
+	err := g.withRepo(true, func(repo string, fetched bool) error {
+		if !fetched {
+			return nil
+		}
+		out, err := g.git(repo, "ls-tree", "--name-only", "HEAD")
+		if err != nil {
+			return err
+		}
+		for _, name := range strings.Fields(out) {
+			data, err := os.ReadFile(filepath.Join(repo, name))
+			if err != nil {
+				// 子目录等不是本程序生成的文件
+				continue
+			}
+			files[name] = data
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	g.files = files
+	return files, nil
+}
+
+// invalidate 远程分支即将改变，丢弃已读取的文件
+func (g *GitUploader) invalidate() {
+	g.mu.Lock()
+	g.files = nil
+	g.mu.Unlock()
+}
+
+// Delete 删除远程分支中的文件
+func (g *GitUploader) Delete(filename string) error {
+	g.invalidate()
+	return g.withRepo(true, func(repo string, fetched bool) error {
+		if !fetched {
+			return ErrNotFound
+		}
+		if _, err := os.Stat(filepath.Join(repo, filepath.Base(filename))); errors.Is(err, os.ErrNotExist) {
+			return ErrNotFound
+		}
+		if _, err := g.git(repo, "rm", "-q", "--", filepath.Base(filename)); err != nil {
+			return err
+		}
+		return g.commitAndPush(repo, "删除 "+filename, false)
+	})
+}
+
+// withRepo 在临时目录中初始化仓库，fetch 为 true 时检出远程分支的最新提交
+// 远程分支不存在时 fetched 为 false，工作区为空
+func (g *GitUploader) withRepo(fetch bool, fn func(repo string, fetched bool) error) error {
+	repo, err := os.MkdirTemp("", "subs-check-git-*")
+	if err != nil {
+		return fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(repo)
+
+	if _, err := g.git(repo, "init", "-q"); err != nil {
+		return err
+	}
+	if _, err := g.git(repo, "checkout", "-q", "-b", g.branch); err != nil {
+		return err
+	}
+
+	fetched := false
+	if fetch {
+		out, err := g.git(repo, "ls-remote", "--heads", g.remote, g.branch)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(out) != "" {
+			if _, err := g.git(repo, "fetch", "-q", "--depth", "1", g.remote, g.branch); err != nil {
+				return err
+			}
+			if _, err := g.git(repo, "reset", "-q", "--hard", "FETCH_HEAD"); err != nil {
+				return err
+			}
+			fetched = true
+		}
+	}
+
+	return fn(repo, fetched)
+}
+
+// commitAndPush 提交工作区的改动并推送，没有改动时跳过
+func (g *GitUploader) commitAndPush(repo, message string, force bool) error {
+	if _, err := g.git(repo, "add", "-A"); err != nil {
+		return err
+	}
+	if _, err := g.git(repo, "diff", "--cached", "--quiet"); err == nil {
+		// 空仓库的第一次提交 diff 也可能为空，仍需判断是否已有提交
+		if _, err := g.git(repo, "rev-parse", "-q", "--verify", "HEAD"); err == nil {
+			return nil
+		}
+	}
+	if _, err := g.git(repo, "commit", "-q", "--allow-empty", "-m", message); err != nil {
+		return err
+	}
+
+	args := []string{"push", "-q", g.remote, "HEAD:refs/heads/" + g.branch}
+	if force {
+		args = append(args, "--force")
+	}
+	_, err := g.git(repo, args...)
+	return err
+}
+
+// git 执行 git 命令，错误信息中的 token 会被隐藏
+func (g *GitUploader) git(repo string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repo
+	cmd.Env = g.env()
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := g.redact(strings.TrimSpace(stderr.String()))
+		return "", fmt.Errorf("git %s 失败: %w %s", args[0], err, msg)
+	}
+	return stdout.String(), nil
+}
+
+// env git 命令的环境变量，包含提交者信息和认证信息
+func (g *GitUploader) env() []string {
+	env := append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_AUTHOR_NAME="+g.authorName,
+		"GIT_AUTHOR_EMAIL="+g.authorEmail,
+		"GIT_COMMITTER_NAME="+g.authorName,
+		"GIT_COMMITTER_EMAIL="+g.authorEmail,
+	)
+	if g.sshKey != "" {
+		// GIT_SSH_COMMAND 由 shell 解析，私钥路径需要按 shell 规则转义
+		env = append(env, "GIT_SSH_COMMAND=ssh -i "+shellQuote(g.sshKey)+" -o StrictHostKeyChecking=accept-new")
+	}
+	if g.authHeader != "" {
+		// 只对远程仓库的地址生效，环境变量只有同一用户可见
+		env = append(env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http."+g.remote+".extraHeader",
+			"GIT_CONFIG_VALUE_0="+g.authHeader,
+		)
+	}
+	return env
+}
+
+// redact 隐藏输出中的 token，包括 URL 编码和请求头中 base64 编码的形式
+func (g *GitUploader) redact(msg string) string {
+	if g.token == "" {
+		return msg
+	}
+	userinfo := strings.TrimPrefix(url.UserPassword("", g.token).String(), ":")
+	secrets := []string{g.token, userinfo, url.QueryEscape(g.token), url.PathEscape(g.token)}
+	if g.authHeader != "" {
+		secrets = append(secrets, strings.TrimPrefix(g.authHeader, "Authorization: Basic "))
+	}
+	for _, token := range secrets {
+		msg = strings.ReplaceAll(msg, token, "***")
+	}
+	return msg
+}
+
+// shellQuote 按 shell 规则用单引号包裹参数，参数中的单引号先结束引号再转义
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,176 @@
+package method
+
+import (
+	"encoding/base64"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
+)
+
+// newBareRepo 创建本地裸仓库作为远程仓库
+func newBareRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("未安装git")
+	}
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init --bare: %v %s", err, out)
+	}
+	return remote
+}
+
+// commitCount 返回远程分支的提交数量
+func commitCount(t *testing.T, remote, branch string) int {
+	t.Helper()
+	out, err := exec.Command("git", "-C", remote, "rev-list", "--count", branch).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git rev-list: %v %s", err, out)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		t.Fatalf("git rev-list: %v", err)
+	}
+	return n
+}
+
+func setGitConfig(t *testing.T, remote string, squash bool) {
+	config.GlobalConfig.GitURL = remote
+	config.GlobalConfig.GitBranch = "subs"
+	config.GlobalConfig.GitSquash = squash
+	t.Cleanup(func() {
+		config.GlobalConfig.GitURL = ""
+		config.GlobalConfig.GitBranch = ""
+		config.GlobalConfig.GitSquash = false
+	})
+}
+
+func TestGitBackend(t *testing.T) {
+	setGitConfig(t, newBareRepo(t), false)
+	testBackendRoundTrip(t, NewGitUploader(config.GlobalConfig.BackendConfig))
+}
+
+func TestGitPutAll(t *testing.T) {
+	tests := []struct {
+		name        string
+		squash      bool
+		wantCommits int
+	}{
+		{name: "保留历史", squash: false, wantCommits: 2},
+		{name: "压缩历史", squash: true, wantCommits: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := newBareRepo(t)
+			setGitConfig(t, remote, tt.squash)
+			g := NewGitUploader(config.GlobalConfig.BackendConfig)
+
+			for _, content := range []string{"a", "b"} {
+				files := []utils.OutputFile{
+					{Name: "node.yaml", Data: []byte(content)},
+					{Name: "stats.json", Data: []byte(content)},
+				}
+				if err := g.PutAll(files, "更新订阅: 可用节点 "+content); err != nil {
+					t.Fatalf("PutAll() error = %v", err)
+				}
+			}
+			// 内容没有变化时不产生新的提交
+			if !tt.squash {
+				if err := g.PutAll([]utils.OutputFile{{Name: "node.yaml", Data: []byte("b")}}, "无变化"); err != nil {
+					t.Fatalf("PutAll() error = %v", err)
+				}
+			}
+
+			if got := commitCount(t, remote, "subs"); got != tt.wantCommits {
+				t.Errorf("commits = %d, want %d", got, tt.wantCommits)
+			}
+			out, err := exec.Command("git", "-C", remote, "log", "-1", "--format=%s", "subs").CombinedOutput()
+			if err != nil {
+				t.Fatalf("git log: %v %s", err, out)
+			}
+			if got := strings.TrimSpace(string(out)); got != "更新订阅: 可用节点 b" {
+				t.Errorf("commit message = %q", got)
+			}
+		})
+	}
+}
+
+func TestGitRedact(t *testing.T) {
+	token := "ghp_a/b+c@d:e"
+	g := &GitUploader{token: token}
+	u := &url.URL{Scheme: "https", Host: "example.com", Path: "/repo.git", User: url.UserPassword("git", token)}
+	msg := "fatal: unable to access '" + u.String() + "/': " + token + " " + url.QueryEscape(token) + " " + url.PathEscape(token)
+	got := g.redact(msg)
+	for _, leaked := range []string{token, url.QueryEscape(token), url.PathEscape(token), "a%2Fb"} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("redact() = %q, 仍然包含 %q", got, leaked)
+		}
+	}
+}
+
+func TestGitCredentials(t *testing.T) {
+	var cfg config.BackendConfig
+	cfg.GitURL = "https://example.com/repo.git"
+	cfg.GitUsername = "user"
+	cfg.GitToken = "secret-token"
+	cfg.GitSSHKey = "/keys/it's key"
+	g := NewGitUploader(cfg)
+
+	// token 不出现在命令行参数使用的远程地址中
+	if strings.Contains(g.remote, cfg.GitToken) {
+		t.Errorf("remote = %q, 包含 token", g.remote)
+	}
+	env := g.env()
+	header := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret-token"))
+	for _, want := range []string{
+		"GIT_CONFIG_KEY_0=http.https://example.com/repo.git.extraHeader",
+		"GIT_CONFIG_VALUE_0=" + header,
+		`GIT_SSH_COMMAND=ssh -i '/keys/it'\''s key' -o StrictHostKeyChecking=accept-new`,
+	} {
+		if !slices.Contains(env, want) {
+			t.Errorf("env 中没有 %q", want)
+		}
+	}
+	if got := g.redact("auth " + header); strings.Contains(got, "secret-token") || strings.Contains(got, header[len("Authorization: Basic "):]) {
+		t.Errorf("redact() = %q", got)
+	}
+}
+
+func TestGitRestoreClonesOnce(t *testing.T) {
+	remote := newBareRepo(t)
+	setGitConfig(t, remote, false)
+	files := []utils.OutputFile{
+		{Name: "node.yaml", Data: []byte("a")},
+		{Name: "stats.json", Data: []byte("b")},
+	}
+	if err := NewGitUploader(config.GlobalConfig.BackendConfig).PutAll(files, "init"); err != nil {
+		t.Fatalf("PutAll() error = %v", err)
+	}
+
+	g := NewGitUploader(config.GlobalConfig.BackendConfig)
+	names, err := g.List()
+	if err != nil || !slices.Equal(names, []string{"node.yaml", "stats.json"}) {
+		t.Fatalf("List() = %v, %v", names, err)
+	}
+	// List 之后不再访问远程仓库
+	if err := os.RemoveAll(remote); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := g.Get(file.Name)
+		if err != nil || string(data) != string(file.Data) {
+			t.Errorf("Get(%s) = %q, %v", file.Name, data, err)
+		}
+	}
+	if _, err := g.Get("missing.yaml"); err != ErrNotFound {
+		t.Errorf("Get(missing.yaml) error = %v, want ErrNotFound", err)
+	}
+}
@@ -1,6 +1,7 @@
 package method
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -67,7 +68,7 @@ func (ls *LocalSaver) Save(yamlData []byte, filename string) error {
 	// 构建文件路径并保存
 	filepath := filepath.Join(ls.OutputPath, filename)
 
-	if err := os.WriteFile(filepath, yamlData, fileMode); err != nil {
+	if err := writeFileAtomic(filepath, yamlData); err != nil {
 		return fmt.Errorf("写入文件失败 [%s]: %w", filename, err)
 	}
 	slog.Info("保存本地成功", "filepath", filepath)
@@ -75,6 +76,147 @@ func (ls *LocalSaver) Save(yamlData []byte, filename string) error {
 	return nil
 }
 
+// SaveAllToLocal 将一次运行的所有文件保存到本地
+func SaveAllToLocal(files []utils.OutputFile) error {
+	saver, err := NewLocalSaver()
+	if err != nil {
+		return fmt.Errorf("创建本地保存器失败: %w", err)
+	}
+
+	return saver.SaveAll(files)
+}
+
+// SaveAll 先把所有文件写入临时文件，全部成功后再依次重命名替换
+// 任意文件写入失败时不替换任何文件，避免输出目录中混合新旧两次运行的结果
+func (ls *LocalSaver) SaveAll(files []utils.OutputFile) error {
+	if err := ls.ensureOutputDir(); err != nil {
+		return fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	for _, file := range files {
+		if err := ls.validateInput(file.Data, file.Name); err != nil {
+			return fmt.Errorf("%w [%s]", err, file.Name)
+		}
+	}
+
+	temps := make([]string, 0, len(files))
+	cleanup := func() {
+		for _, temp := range temps {
+			os.Remove(temp)
+		}
+	}
+	for _, file := range files {
+		temp, err := writeTemp(filepath.Join(ls.OutputPath, file.Name), file.Data)
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("写入文件失败 [%s]: %w", file.Name, err)
+		}
+		temps = append(temps, temp)
+	}
+
+	for i, file := range files {
+		target := filepath.Join(ls.OutputPath, file.Name)
+		if err := os.Rename(temps[i], target); err != nil {
+			temps = temps[i:]
+			cleanup()
+			return fmt.Errorf("替换文件失败 [%s]: %w", file.Name, err)
+		}
+	}
+	slog.Info("保存本地成功", "dir", ls.OutputPath, "files", len(files))
+
+	return nil
+}
+
+// writeFileAtomic 写入临时文件后重命名，读取方不会读到写了一半的文件
+func writeFileAtomic(path string, data []byte) error {
+	temp, err := writeTemp(path, data)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return nil
+}
+
+// writeTemp 在目标文件所在目录创建并写入临时文件，返回临时文件路径
+func writeTemp(path string, data []byte) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	name := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(name, fileMode)
+	}
+	if err != nil {
+		os.Remove(name)
+		return "", err
+	}
+	return name, nil
+}
+
+// Name 后端名称
+func (ls *LocalSaver) Name() string {
+	return "local"
+}
+
+// Put 保存文件到输出目录
+func (ls *LocalSaver) Put(filename string, data []byte) error {
+	return ls.Save(data, filename)
+}
+
+// Get 读取输出目录中的文件
+func (ls *LocalSaver) Get(filename string) ([]byte, error) {
+	if filepath.Base(filename) != filename {
+		return nil, fmt.Errorf("filename包含非法字符: %s", filename)
+	}
+	data, err := os.ReadFile(filepath.Join(ls.OutputPath, filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// List 列出输出目录中的文件
+func (ls *LocalSaver) List() ([]string, error) {
+	entries, err := os.ReadDir(ls.OutputPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// Delete 删除输出目录中的文件
+func (ls *LocalSaver) Delete(filename string) error {
+	if filepath.Base(filename) != filename {
+		return fmt.Errorf("filename包含非法字符: %s", filename)
+	}
+	err := os.Remove(filepath.Join(ls.OutputPath, filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
 // ensureOutputDir 确保输出目录存在
 func (ls *LocalSaver) ensureOutputDir() error {
 	if _, err := os.Stat(ls.OutputPath); os.IsNotExist(err) {
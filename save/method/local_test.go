@@ -0,0 +1,105 @@
+package method
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
+)
+
+func TestLocalBackend(t *testing.T) {
+	config.GlobalConfig.OutputDir = t.TempDir()
+	t.Cleanup(func() { config.GlobalConfig.OutputDir = "" })
+
+	saver, err := NewLocalSaver()
+	if err != nil {
+		t.Fatalf("NewLocalSaver() error = %v", err)
+	}
+	testBackendRoundTrip(t, saver)
+}
+
+// TestSaveAllConcurrentFetch 保存期间并发读取，读到的文件必须完整
+func TestSaveAllConcurrentFetch(t *testing.T) {
+	config.GlobalConfig.OutputDir = t.TempDir()
+	t.Cleanup(func() { config.GlobalConfig.OutputDir = "" })
+
+	saver, err := NewLocalSaver()
+	if err != nil {
+		t.Fatalf("NewLocalSaver() error = %v", err)
+	}
+
+	// 每一代文件内容为同一字符重复，长度随代数变化，截断或混写都能被发现
+	generation := func(i int) []utils.OutputFile {
+		content := []byte(strings.Repeat(string(rune('a'+i%26)), 256*1024+i))
+		return []utils.OutputFile{
+			{Name: "node.yaml", Data: content},
+			{Name: "sub.yaml", Data: content},
+		}
+	}
+	if err := saver.SaveAll(generation(0)); err != nil {
+		t.Fatalf("SaveAll() error = %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join(saver.OutputPath, filepath.Base(r.URL.Path)))
+	}))
+	defer server.Close()
+
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	errs := make(chan string, 100)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stop.Load() {
+				for _, name := range []string{"node.yaml", "sub.yaml"} {
+					resp, err := http.Get(server.URL + "/" + name)
+					if err != nil {
+						errs <- err.Error()
+						return
+					}
+					data, err := io.ReadAll(resp.Body)
+					resp.Body.Close()
+					if err != nil {
+						errs <- err.Error()
+						return
+					}
+					if len(data) < 256*1024 || !bytes.Equal(data, bytes.Repeat(data[:1], len(data))) {
+						errs <- "读取到不完整的文件: " + name
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for i := 1; i <= 50; i++ {
+		if err := saver.SaveAll(generation(i)); err != nil {
+			t.Fatalf("SaveAll() error = %v", err)
+		}
+	}
+	stop.Store(true)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	entries, err := os.ReadDir(saver.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("输出目录残留临时文件: %d 个文件", len(entries))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 
 	"github.com/beck-8/subs-check/config"
@@ -11,39 +12,42 @@ import (
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+// S3Backend S3/MinIO 存储后端
+type S3Backend struct {
+	client *minio.Client
+	bucket string
+}
+
 // ValiS3Config checks if the MinIO configuration is complete.
-func ValiS3Config() error {
-	if config.GlobalConfig.S3Endpoint == "" {
+func ValiS3Config(cfg config.BackendConfig) error {
+	if cfg.S3Endpoint == "" {
 		return fmt.Errorf("S3Endpoint is not configured")
 	}
-	if config.GlobalConfig.S3AccessID == "" {
+	if cfg.S3AccessID == "" {
 		return fmt.Errorf("S3AccessID is not configured")
 	}
-	if config.GlobalConfig.S3SecretKey == "" {
+	if cfg.S3SecretKey == "" {
 		return fmt.Errorf("S3SecretKey is not configured")
 	}
-	if config.GlobalConfig.S3Bucket == "" {
+	if cfg.S3Bucket == "" {
 		return fmt.Errorf("S3Bucket is not configured")
 	}
 	return nil
 }
 
-// UploadToS3 uploads data to a MinIO bucket.
-// The 'filename' parameter will be used as the object name in the bucket.
-func UploadToS3(data []byte, filename string) error {
-	ctx := context.Background()
-	endpoint := config.GlobalConfig.S3Endpoint
-	accessKeyID := config.GlobalConfig.S3AccessID
-	secretAccessKey := config.GlobalConfig.S3SecretKey
-	useSSL := config.GlobalConfig.S3UseSSL // e.g., true for HTTPS, false for HTTP
-	bucketName := config.GlobalConfig.S3Bucket
+// NewS3Backend initializes a MinIO client from the global config.
+func NewS3Backend(cfg config.BackendConfig) (*S3Backend, error) {
+	endpoint := cfg.S3Endpoint
+	accessKeyID := cfg.S3AccessID
+	secretAccessKey := cfg.S3SecretKey
+	useSSL := cfg.S3UseSSL // e.g., true for HTTPS, false for HTTP
 
 	// Initialize minio client object.
 	minioClient, err := minio.New(endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
 		Secure: useSSL,
 		BucketLookup: func() minio.BucketLookupType {
-			switch config.GlobalConfig.S3BucketLookup {
+			switch cfg.S3BucketLookup {
 			case "dns":
 				return minio.BucketLookupDNS
 			case "path":
@@ -56,16 +60,41 @@ func UploadToS3(data []byte, filename string) error {
 		}(),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to initialize MinIO client: %w", err)
+		return nil, fmt.Errorf("failed to initialize MinIO client: %w", err)
+	}
+
+	return &S3Backend{
+		client: minioClient,
+		bucket: cfg.S3Bucket,
+	}, nil
+}
+
+// UploadToS3 uploads data to a MinIO bucket.
+// The 'filename' parameter will be used as the object name in the bucket.
+func UploadToS3(data []byte, filename string) error {
+	backend, err := NewS3Backend(config.GlobalConfig.BackendConfig)
+	if err != nil {
+		return err
 	}
+	return backend.Put(filename, data)
+}
+
+// Name returns the backend name.
+func (s *S3Backend) Name() string {
+	return "S3"
+}
+
+// Put uploads a single object.
+func (s *S3Backend) Put(filename string, data []byte) error {
+	ctx := context.Background()
 
 	// Check if the bucket exists.
-	exists, err := minioClient.BucketExists(ctx, bucketName)
+	exists, err := s.client.BucketExists(ctx, s.bucket)
 	if err != nil {
-		return fmt.Errorf("failed to check if bucket '%s' exists: %w", bucketName, err)
+		return fmt.Errorf("failed to check if bucket '%s' exists: %w", s.bucket, err)
 	}
 	if !exists {
-		return fmt.Errorf("bucket '%s' does not exist", bucketName)
+		return fmt.Errorf("bucket '%s' does not exist", s.bucket)
 	}
 
 	// Upload the data.
@@ -73,11 +102,54 @@ func UploadToS3(data []byte, filename string) error {
 	objectName := filename
 	contentType := "application/octet-stream"
 
-	info, err := minioClient.PutObject(ctx, bucketName, objectName, reader, int64(len(data)), minio.PutObjectOptions{ContentType: contentType})
+	info, err := s.client.PutObject(ctx, s.bucket, objectName, reader, int64(len(data)), minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("failed to upload '%s' to bucket '%s': %w", objectName, s.bucket, err)
+	}
+
+	slog.Debug(fmt.Sprintf("Successfully uploaded '%s' of size %d to bucket '%s'. ETag: %s", objectName, info.Size, s.bucket, info.ETag))
+	return nil
+}
+
+// Get downloads a single object.
+func (s *S3Backend) Get(filename string) ([]byte, error) {
+	object, err := s.client.GetObject(context.Background(), s.bucket, filename, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.convertError(filename, err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
 	if err != nil {
-		return fmt.Errorf("failed to upload '%s' to bucket '%s': %w", objectName, bucketName, err)
+		return nil, s.convertError(filename, err)
 	}
+	return data, nil
+}
+
+// List lists the objects in the bucket root.
+func (s *S3Backend) List() ([]string, error) {
+	var names []string
+	for object := range s.client.ListObjects(context.Background(), s.bucket, minio.ListObjectsOptions{}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list bucket '%s': %w", s.bucket, object.Err)
+		}
+		names = append(names, object.Key)
+	}
+	return names, nil
+}
 
-	slog.Info(fmt.Sprintf("Successfully uploaded '%s' of size %d to bucket '%s'. ETag: %s", objectName, info.Size, bucketName, info.ETag))
+// Delete removes a single object.
+func (s *S3Backend) Delete(filename string) error {
+	if err := s.client.RemoveObject(context.Background(), s.bucket, filename, minio.RemoveObjectOptions{}); err != nil {
+		return s.convertError(filename, err)
+	}
 	return nil
 }
+
+// convertError maps a missing object to ErrNotFound.
+func (s *S3Backend) convertError(filename string, err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return fmt.Errorf("failed to access '%s' in bucket '%s': %w", filename, s.bucket, err)
+}
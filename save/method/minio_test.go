@@ -1,7 +1,16 @@
 package method
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/beck-8/subs-check/config"
 )
@@ -38,3 +47,116 @@ func TestUploadToS3(t *testing.T) {
 		})
 	}
 }
+
+// newFakeS3 启动一个内存中的 S3 服务，只支持单个 bucket 的基本对象操作
+func newFakeS3(t *testing.T, bucket string) *httptest.Server {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["location"]; ok {
+			w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`))
+			return
+		}
+
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		name, key, _ := strings.Cut(path, "/")
+		if name != bucket {
+			writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case key == "" && r.Method == "HEAD":
+			w.WriteHeader(http.StatusOK)
+		case key == "" && r.Method == "GET":
+			var b strings.Builder
+			b.WriteString(`<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`)
+			fmt.Fprintf(&b, `<Name>%s</Name><KeyCount>%d</KeyCount><IsTruncated>false</IsTruncated>`, bucket, len(objects))
+			for key, data := range objects {
+				fmt.Fprintf(&b, `<Contents><Key>%s</Key><Size>%d</Size></Contents>`, key, len(data))
+			}
+			b.WriteString(`</ListBucketResult>`)
+			w.Write([]byte(b.String()))
+		case r.Method == "PUT":
+			data, err := readS3Body(r)
+			if err != nil {
+				writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+				return
+			}
+			objects[key] = data
+			w.Header().Set("ETag", `"etag"`)
+			w.WriteHeader(http.StatusOK)
+		case r.Method == "GET":
+			data, ok := objects[key]
+			if !ok {
+				writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+			w.Header().Set("ETag", `"etag"`)
+			w.Write(data)
+		case r.Method == "DELETE":
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// readS3Body 读取上传内容，处理 minio-go 在 HTTP 下使用的 aws-chunked 编码
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data []byte
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data, nil
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func TestS3Backend(t *testing.T) {
+	server := newFakeS3(t, "public")
+	config.GlobalConfig.S3Endpoint = strings.TrimPrefix(server.URL, "http://")
+	config.GlobalConfig.S3AccessID = "123"
+	config.GlobalConfig.S3SecretKey = "123"
+	config.GlobalConfig.S3Bucket = "public"
+	config.GlobalConfig.S3UseSSL = false
+	config.GlobalConfig.S3BucketLookup = "path"
+
+	backend, err := NewS3Backend(config.GlobalConfig.BackendConfig)
+	if err != nil {
+		t.Fatalf("NewS3Backend(config.GlobalConfig.BackendConfig) error = %v", err)
+	}
+	testBackendRoundTrip(t, backend)
+}
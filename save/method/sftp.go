@@ -0,0 +1,251 @@
+package method
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SFTPUploader 处理 SFTP 上传的结构体
+type SFTPUploader struct {
+	host       string
+	username   string
+	password   string
+	privateKey string
+	passphrase string
+	hostKey    string
+	dir        string
+}
+
+// NewSFTPUploader 创建新的 SFTP 上传器
+func NewSFTPUploader(cfg config.BackendConfig) *SFTPUploader {
+	dir := cfg.SFTPDir
+	if dir == "" {
+		dir = "."
+	}
+	return &SFTPUploader{
+		host:       cfg.SFTPHost,
+		username:   cfg.SFTPUsername,
+		password:   cfg.SFTPPassword,
+		privateKey: cfg.SFTPPrivateKey,
+		passphrase: cfg.SFTPPassphrase,
+		hostKey:    cfg.SFTPHostKey,
+		dir:        dir,
+	}
+}
+
+// ValiSFTPConfig 验证SFTP配置
+func ValiSFTPConfig(cfg config.BackendConfig) error {
+	if cfg.SFTPHost == "" {
+		return fmt.Errorf("sftp 地址未配置")
+	}
+	if cfg.SFTPUsername == "" {
+		return fmt.Errorf("sftp 用户名未配置")
+	}
+	if cfg.SFTPPassword == "" && cfg.SFTPPrivateKey == "" {
+		return fmt.Errorf("sftp 密码和私钥至少配置一个")
+	}
+	return nil
+}
+
+// Name 后端名称
+func (s *SFTPUploader) Name() string {
+	return "sftp"
+}
+
+// Put 上传单个文件
+func (s *SFTPUploader) Put(filename string, data []byte) error {
+	return s.withClient(func(client *sftp.Client) error {
+		return s.put(client, filename, data)
+	})
+}
+
+// PutAll 复用同一个连接上传所有文件，避免每个文件重新建立 SSH 连接
+func (s *SFTPUploader) PutAll(files []utils.OutputFile, message string) error {
+	return s.withClient(func(client *sftp.Client) error {
+		var errs []error
+		for _, file := range files {
+			if err := s.put(client, file.Name, file.Data); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file.Name, err))
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// put 先写入临时文件再重命名，避免客户端读到不完整的文件
+func (s *SFTPUploader) put(client *sftp.Client, filename string, data []byte) error {
+	if err := client.MkdirAll(s.dir); err != nil {
+		return fmt.Errorf("创建远程目录失败: %w", err)
+	}
+
+	target := path.Join(s.dir, filename)
+	temp := path.Join(s.dir, "."+filename+".tmp")
+	f, err := client.Create(temp)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		client.Remove(temp)
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		client.Remove(temp)
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+
+	// 不支持 posix-rename 扩展的服务器先删除旧文件再重命名
+	if err := client.PosixRename(temp, target); err != nil {
+		client.Remove(target)
+		if err := client.Rename(temp, target); err != nil {
+			client.Remove(temp)
+			return fmt.Errorf("重命名文件失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// Get 下载单个文件
+func (s *SFTPUploader) Get(filename string) ([]byte, error) {
+	var data []byte
+	err := s.withClient(func(client *sftp.Client) error {
+		f, err := client.Open(path.Join(s.dir, filename))
+		if err != nil {
+			return convertNotExist(err)
+		}
+		defer f.Close()
+		data, err = io.ReadAll(f)
+		return err
+	})
+	return data, err
+}
+
+// List 列出远程目录中的文件
+func (s *SFTPUploader) List() ([]string, error) {
+	var names []string
+	err := s.withClient(func(client *sftp.Client) error {
+		entries, err := client.ReadDir(s.dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Mode().IsRegular() {
+				names = append(names, entry.Name())
+			}
+		}
+		return nil
+	})
+	return names, err
+}
+
+// Delete 删除单个文件
+func (s *SFTPUploader) Delete(filename string) error {
+	return s.withClient(func(client *sftp.Client) error {
+		return convertNotExist(client.Remove(path.Join(s.dir, filename)))
+	})
+}
+
+// withClient 建立 SFTP 连接并执行操作
+func (s *SFTPUploader) withClient(fn func(client *sftp.Client) error) error {
+	clientConfig, err := s.sshConfig()
+	if err != nil {
+		return err
+	}
+	conn, err := ssh.Dial("tcp", s.host, clientConfig)
+	if err != nil {
+		return fmt.Errorf("连接 sftp 服务器失败: %w", err)
+	}
+	defer conn.Close()
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		return fmt.Errorf("创建 sftp 客户端失败: %w", err)
+	}
+	defer client.Close()
+
+	return fn(client)
+}
+
+// sshConfig 根据配置生成 ssh 客户端配置
+func (s *SFTPUploader) sshConfig() (*ssh.ClientConfig, error) {
+	var auths []ssh.AuthMethod
+	if s.privateKey != "" {
+		key, err := os.ReadFile(s.privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("读取私钥失败: %w", err)
+		}
+		var signer ssh.Signer
+		if s.passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(s.passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析私钥失败: %w", err)
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+	if s.password != "" {
+		auths = append(auths, ssh.Password(s.password))
+	}
+
+	hostKeyCallback, err := s.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.ClientConfig{
+		User:            s.username,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         30 * time.Second,
+	}, nil
+}
+
+// hostKeyCallback 配置了主机公钥指纹时校验指纹，否则使用 ~/.ssh/known_hosts 校验，
+// 两者都没有时不校验主机公钥，并输出警告
+func (s *SFTPUploader) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if s.hostKey != "" {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if fingerprint := ssh.FingerprintSHA256(key); fingerprint != s.hostKey {
+				return fmt.Errorf("主机公钥指纹不匹配: %s", fingerprint)
+			}
+			return nil
+		}, nil
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		knownHostsFile := filepath.Join(home, ".ssh", "known_hosts")
+		if _, err := os.Stat(knownHostsFile); err == nil {
+			callback, err := knownhosts.New(knownHostsFile)
+			if err != nil {
+				return nil, fmt.Errorf("读取 known_hosts 失败: %w", err)
+			}
+			return callback, nil
+		}
+	}
+	slog.Warn("未配置 sftp-host-key 且不存在 ~/.ssh/known_hosts，跳过主机公钥校验，连接可能被中间人劫持")
+	return ssh.InsecureIgnoreHostKey(), nil
+}
+
+// convertNotExist 将文件不存在的错误转换为 ErrNotFound
+func convertNotExist(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,179 @@
+package method
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// newSFTPServer 启动一个使用内存文件系统的 SFTP 服务，返回监听地址、主机公钥和已建立的连接数
+func newSFTPServer(t *testing.T, password string) (string, ssh.PublicKey, *atomic.Int32) {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) != password {
+				return nil, ssh.ErrNoAuth
+			}
+			return nil, nil
+		},
+	}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	// 所有连接共享同一个内存文件系统
+	handlers := sftp.InMemHandler()
+	var conns atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns.Add(1)
+			go serveSFTP(conn, serverConfig, handlers)
+		}
+	}()
+	return listener.Addr().String(), signer.PublicKey(), &conns
+}
+
+func serveSFTP(conn net.Conn, serverConfig *ssh.ServerConfig, handlers sftp.Handlers) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				req.Reply(req.Type == "subsystem" && string(req.Payload[4:]) == "sftp", nil)
+			}
+		}()
+		go func() {
+			server := sftp.NewRequestServer(channel, handlers)
+			server.Serve()
+			server.Close()
+		}()
+	}
+}
+
+func TestSFTPBackend(t *testing.T) {
+	addr, hostKey, conns := newSFTPServer(t, "pass")
+	config.GlobalConfig.SFTPHost = addr
+	config.GlobalConfig.SFTPUsername = "user"
+	config.GlobalConfig.SFTPPassword = "pass"
+	config.GlobalConfig.SFTPDir = "/data/subs"
+	config.GlobalConfig.SFTPHostKey = ssh.FingerprintSHA256(hostKey)
+
+	testBackendRoundTrip(t, NewSFTPUploader(config.GlobalConfig.BackendConfig))
+
+	// 覆盖已有文件
+	backend := NewSFTPUploader(config.GlobalConfig.BackendConfig)
+	if err := backend.Put("node.yaml", []byte("new")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if data, err := backend.Get("node.yaml"); err != nil || string(data) != "new" {
+		t.Errorf("Get() = %q, %v", data, err)
+	}
+
+	// 批量上传只建立一次连接
+	files := []utils.OutputFile{
+		{Name: "all.yaml", Data: []byte("all")},
+		{Name: "mihomo.yaml", Data: []byte("mihomo")},
+		{Name: "base64.txt", Data: []byte("base64")},
+	}
+	before := conns.Load()
+	if err := backend.PutAll(files, "更新订阅"); err != nil {
+		t.Fatalf("PutAll() error = %v", err)
+	}
+	if got := conns.Load() - before; got != 1 {
+		t.Errorf("PutAll() 建立了 %d 个连接，期望 1 个", got)
+	}
+	for _, file := range files {
+		if data, err := backend.Get(file.Name); err != nil || string(data) != string(file.Data) {
+			t.Errorf("Get(%s) = %q, %v", file.Name, data, err)
+		}
+	}
+
+	// 主机公钥不匹配时拒绝连接
+	config.GlobalConfig.SFTPHostKey = "SHA256:invalid"
+	if _, err := NewSFTPUploader(config.GlobalConfig.BackendConfig).List(); err == nil {
+		t.Errorf("List() with wrong host key should fail")
+	}
+}
+
+func TestSFTPKnownHosts(t *testing.T) {
+	addr, hostKey, _ := newSFTPServer(t, "pass")
+	_, otherKey, _ := newSFTPServer(t, "pass")
+	cfg := config.BackendConfig{
+		SFTPHost:     addr,
+		SFTPUsername: "user",
+		SFTPPassword: "pass",
+		SFTPDir:      "/data/subs",
+	}
+
+	tests := []struct {
+		name       string
+		knownHosts string // 为空时不创建 known_hosts
+		wantErr    bool
+	}{
+		{"未配置指纹且没有 known_hosts", "", false},
+		{"known_hosts 中的公钥匹配", knownHostsLine(addr, hostKey), false},
+		{"known_hosts 中的公钥不匹配", knownHostsLine(addr, otherKey), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			if tt.knownHosts != "" {
+				if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte(tt.knownHosts), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := NewSFTPUploader(cfg).List()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("List() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// knownHostsLine 生成 known_hosts 中的一行
+func knownHostsLine(addr string, key ssh.PublicKey) string {
+	return knownhosts.Line([]string{knownhosts.Normalize(addr)}, key) + "\n"
+}
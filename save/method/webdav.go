@@ -2,21 +2,18 @@ package method
 
 import (
 	"bytes"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"path"
+	"strings"
 	"time"
 
-	"log/slog"
-
 	"github.com/beck-8/subs-check/config"
 )
 
-var (
-	webdavMaxRetries = 3
-	webdavRetryDelay = 2 * time.Second
-)
-
 // WebDAVUploader 处理 WebDAV 上传的结构体
 type WebDAVUploader struct {
 	client   *http.Client
@@ -26,119 +23,120 @@ type WebDAVUploader struct {
 }
 
 // NewWebDAVUploader 创建新的 WebDAV 上传器
-func NewWebDAVUploader() *WebDAVUploader {
+func NewWebDAVUploader(cfg config.BackendConfig) *WebDAVUploader {
+	baseURL := cfg.WebDAVURL
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
 	return &WebDAVUploader{
 		client:   &http.Client{Timeout: 30 * time.Second},
-		baseURL:  config.GlobalConfig.WebDAVURL,
-		username: config.GlobalConfig.WebDAVUsername,
-		password: config.GlobalConfig.WebDAVPassword,
+		baseURL:  baseURL,
+		username: cfg.WebDAVUsername,
+		password: cfg.WebDAVPassword,
 	}
 }
 
-// UploadToWebDAV 上传数据到 WebDAV 的入口函数
-func UploadToWebDAV(yamlData []byte, filename string) error {
-	uploader := NewWebDAVUploader()
-	return uploader.Upload(yamlData, filename)
-}
-
 // ValiWebDAVConfig 验证WebDAV配置
-func ValiWebDAVConfig() error {
-	if config.GlobalConfig.WebDAVURL == "" {
+func ValiWebDAVConfig(cfg config.BackendConfig) error {
+	if cfg.WebDAVURL == "" {
 		return fmt.Errorf("webdav URL未配置")
 	}
-	if config.GlobalConfig.WebDAVUsername == "" {
+	if cfg.WebDAVUsername == "" {
 		return fmt.Errorf("webdav 用户名未配置")
 	}
-	if config.GlobalConfig.WebDAVPassword == "" {
+	if cfg.WebDAVPassword == "" {
 		return fmt.Errorf("webdav 密码未配置")
 	}
 	return nil
 }
 
-// Upload 执行上传操作
-func (w *WebDAVUploader) Upload(yamlData []byte, filename string) error {
-	if err := w.validateInput(yamlData, filename); err != nil {
-		return err
-	}
-
-	return w.uploadWithRetry(yamlData, filename)
+// Name 后端名称
+func (w *WebDAVUploader) Name() string {
+	return "webdav"
 }
 
-// validateInput 验证输入参数
-func (w *WebDAVUploader) validateInput(yamlData []byte, filename string) error {
-	if len(yamlData) == 0 {
-		return fmt.Errorf("yaml数据为空")
-	}
-	if filename == "" {
-		return fmt.Errorf("文件名不能为空")
-	}
-	if w.baseURL == "" {
-		return fmt.Errorf("webdav URL未配置")
-	}
-	return nil
+// Put 执行单次上传
+func (w *WebDAVUploader) Put(filename string, data []byte) error {
+	_, err := w.do("PUT", w.baseURL+url.PathEscape(filename), data, nil)
+	return err
 }
 
-// uploadWithRetry 带重试机制的上传
-func (w *WebDAVUploader) uploadWithRetry(yamlData []byte, filename string) error {
-	var lastErr error
+// Get 下载单个文件
+func (w *WebDAVUploader) Get(filename string) ([]byte, error) {
+	return w.do("GET", w.baseURL+url.PathEscape(filename), nil, nil)
+}
 
-	for attempt := 0; attempt < webdavMaxRetries; attempt++ {
-		if err := w.doUpload(yamlData, filename); err != nil {
-			lastErr = err
-			slog.Error(fmt.Sprintf("webdav上传失败(尝试 %d/%d) %v", attempt+1, webdavMaxRetries, err))
-			time.Sleep(webdavRetryDelay)
-			continue
-		}
-		slog.Info("webdav上传成功", "filename", filename)
-		return nil
-	}
+// Delete 删除单个文件
+func (w *WebDAVUploader) Delete(filename string) error {
+	_, err := w.do("DELETE", w.baseURL+url.PathEscape(filename), nil, nil)
+	return err
+}
 
-	return fmt.Errorf("webdav上传失败，已重试%d次: %w", webdavMaxRetries, lastErr)
+// propfindResponse PROPFIND 返回的 multistatus 结构
+type propfindResponse struct {
+	Responses []struct {
+		Href       string `xml:"href"`
+		Collection *struct {
+		} `xml:"propstat>prop>resourcetype>collection"`
+	} `xml:"response"`
 }
 
-// doUpload 执行单次上传
-func (w *WebDAVUploader) doUpload(yamlData []byte, filename string) error {
-	req, err := w.createRequest(yamlData, filename)
+// List 使用 PROPFIND 列出目录中的文件
+func (w *WebDAVUploader) List() ([]string, error) {
+	body, err := w.do("PROPFIND", w.baseURL, nil, map[string]string{"Depth": "1"})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	resp, err := w.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("发送请求失败: %w", err)
+	var result propfindResponse
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析文件列表失败: %w", err)
 	}
-	defer resp.Body.Close()
 
-	return w.checkResponse(resp)
-}
-
-// createRequest 创建HTTP请求
-func (w *WebDAVUploader) createRequest(yamlData []byte, filename string) (*http.Request, error) {
-	baseURL := w.baseURL
-	if baseURL[len(baseURL)-1] != '/' {
-		baseURL += "/"
+	var names []string
+	for _, resp := range result.Responses {
+		if resp.Collection != nil {
+			continue
+		}
+		href, err := url.PathUnescape(resp.Href)
+		if err != nil {
+			href = resp.Href
+		}
+		names = append(names, path.Base(href))
 	}
+	return names, nil
+}
 
-	url := baseURL + filename
-
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(yamlData))
+// do 发送 WebDAV 请求
+func (w *WebDAVUploader) do(method, target string, data []byte, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
 	req.SetBasicAuth(w.username, w.password)
-	req.Header.Set("Content-Type", "application/x-yaml")
-	return req, nil
-}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/x-yaml")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
 
-// checkResponse 检查响应结果
-func (w *WebDAVUploader) checkResponse(resp *http.Response) error {
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败(状态码: %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("读取响应失败(状态码: %d): %w", resp.StatusCode, err)
-		}
-		return fmt.Errorf("上传失败(状态码: %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("请求失败(状态码: %d): %s", resp.StatusCode, string(body))
 	}
-	return nil
+	return body, nil
 }
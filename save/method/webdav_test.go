@@ -0,0 +1,74 @@
+package method
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+// newFakeWebDAV 启动一个内存中的 WebDAV 服务，文件位于 /dav/ 下
+func newFakeWebDAV(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	files := make(map[string][]byte)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/dav/")
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case "PUT":
+			data, _ := io.ReadAll(r.Body)
+			files[name] = data
+			w.WriteHeader(http.StatusCreated)
+		case "GET":
+			data, ok := files[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case "DELETE":
+			if _, ok := files[name]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(files, name)
+			w.WriteHeader(http.StatusNoContent)
+		case "PROPFIND":
+			var b strings.Builder
+			b.WriteString(`<?xml version="1.0" encoding="utf-8"?><D:multistatus xmlns:D="DAV:">`)
+			b.WriteString(`<D:response><D:href>/dav/</D:href><D:propstat><D:prop><D:resourcetype><D:collection/></D:resourcetype></D:prop></D:propstat></D:response>`)
+			for name := range files {
+				fmt.Fprintf(&b, `<D:response><D:href>/dav/%s</D:href><D:propstat><D:prop><D:resourcetype/></D:prop></D:propstat></D:response>`, url.PathEscape(name))
+			}
+			b.WriteString(`</D:multistatus>`)
+			w.WriteHeader(http.StatusMultiStatus)
+			w.Write([]byte(b.String()))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebDAVBackend(t *testing.T) {
+	server := newFakeWebDAV(t)
+	config.GlobalConfig.WebDAVURL = server.URL + "/dav"
+	config.GlobalConfig.WebDAVUsername = "user"
+	config.GlobalConfig.WebDAVPassword = "pass"
+
+	testBackendRoundTrip(t, NewWebDAVUploader(config.GlobalConfig.BackendConfig))
+}
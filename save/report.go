@@ -0,0 +1,212 @@
+package save
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beck-8/subs-check/check"
+	"github.com/beck-8/subs-check/config"
+	proxyutils "github.com/beck-8/subs-check/proxy"
+	"github.com/beck-8/subs-check/save/method"
+	"github.com/beck-8/subs-check/utils"
+)
+
+const reportDirName = "report"
+
+// reportNames 运行报告的文件名
+var reportNames = []string{"report.json", "report.csv"}
+
+// NodeReport 运行报告中的单个节点
+type NodeReport struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	Server       string   `json:"server"`
+	Port         string   `json:"port"`
+	Subscription string   `json:"subscription,omitempty"`
+	Passed       bool     `json:"passed"`
+	Reason       string   `json:"reason,omitempty"`
+	Latency      int      `json:"latency,omitempty"`
+	Speed        int      `json:"speed,omitempty"`
+	IP           string   `json:"ip,omitempty"`
+	Country      string   `json:"country,omitempty"`
+	Risk         string   `json:"risk,omitempty"`
+	Platforms    []string `json:"platforms,omitempty"`
+	Youtube      string   `json:"youtube,omitempty"`
+	TikTok       string   `json:"tiktok,omitempty"`
+}
+
+// RunReport 单次检测的运行报告，包含所有获取到的节点
+type RunReport struct {
+	Started   time.Time           `json:"started"`
+	Finished  time.Time           `json:"finished"`
+	Duration  float64             `json:"duration"`
+	Traffic   uint64              `json:"traffic"`
+	Fetched   int                 `json:"fetched"`
+	Deduped   int                 `json:"deduped"`
+	Available int                 `json:"available"`
+	Partial   bool                `json:"partial,omitempty"`
+	Failures  *utils.FailureStats `json:"failures,omitempty"`
+	Nodes     []NodeReport        `json:"nodes"`
+}
+
+// buildReport 根据可用节点和上一次检测的运行信息生成运行报告，没有运行信息时返回 nil
+func buildReport(results []check.Result) *RunReport {
+	run := check.LastRun()
+	if run == nil {
+		return nil
+	}
+	report := &RunReport{
+		Started:   run.Started,
+		Finished:  run.Finished,
+		Duration:  run.Finished.Sub(run.Started).Seconds(),
+		Traffic:   run.Traffic,
+		Fetched:   run.Fetched,
+		Deduped:   run.Deduped,
+		Available: len(results),
+		Partial:   run.Partial,
+		Failures:  run.Failures,
+		Nodes:     make([]NodeReport, 0, len(results)+len(run.Failed)),
+	}
+	for _, result := range results {
+		report.Nodes = append(report.Nodes, NewNodeReport(result))
+	}
+	for _, result := range run.Failed {
+		report.Nodes = append(report.Nodes, NewNodeReport(result))
+	}
+	return report
+}
+
+// NewNodeReport 将检测结果转换为报告中的节点
+func NewNodeReport(result check.Result) NodeReport {
+	node := NodeReport{
+		ID:           proxyutils.ProxyID(result.Proxy),
+		Subscription: utils.SubscriptionName(result.SubURL, result.SubTag),
+		Passed:       result.Failure == "",
+		Reason:       string(result.Failure),
+		Latency:      result.Latency,
+		Speed:        result.Speed,
+		IP:           result.IP,
+		Country:      strings.ToUpper(result.Country),
+		Risk:         result.IPRisk,
+		Platforms:    resultPlatforms(result),
+		Youtube:      result.Youtube,
+		TikTok:       result.TikTok,
+	}
+	node.Name, _ = result.Proxy["name"].(string)
+	node.Type, _ = result.Proxy["type"].(string)
+	node.Server, _ = result.Proxy["server"].(string)
+	if port, ok := result.Proxy["port"]; ok {
+		node.Port = fmt.Sprint(port)
+	}
+	return node
+}
+
+// reportSaver 运行报告目录，默认位于配置目录下
+// 不放在输出目录中，因为输出目录会通过 /sub/ 公开访问，报告中包含失败节点的服务器等信息
+func reportSaver() *method.LocalSaver {
+	dir := config.GlobalConfig.ReportDir
+	if dir == "" {
+		dir = filepath.Join(utils.GetConfigDir(), reportDirName)
+	}
+	migrateLegacyReports(dir)
+	return &method.LocalSaver{BasePath: utils.GetExecutablePath(), OutputPath: dir}
+}
+
+// migrateLegacyReports 将旧版本保存在输出目录中的运行报告移动到报告目录
+func migrateLegacyReports(dir string) {
+	saver, err := method.NewLocalSaver()
+	if err != nil || saver.OutputPath == dir {
+		return
+	}
+	for _, name := range reportNames {
+		legacy := filepath.Join(saver.OutputPath, name)
+		if _, err := os.Stat(legacy); err != nil {
+			continue
+		}
+		target := filepath.Join(dir, name)
+		if _, err := os.Stat(target); err == nil {
+			// 报告目录中已有更新的报告，旧报告直接删除
+			err = os.Remove(legacy)
+		} else if err = os.MkdirAll(dir, 0755); err == nil {
+			err = os.Rename(legacy, target)
+		}
+		if err != nil {
+			slog.Warn(fmt.Sprintf("移动旧版本的运行报告失败，输出目录中的报告会被公开访问，请手动删除: %s", legacy))
+			continue
+		}
+		slog.Info("已将运行报告移动到报告目录", "from", legacy, "to", target)
+	}
+}
+
+// saveReport 保存运行报告到报告目录，不发布到远程后端
+func saveReport(files []utils.OutputFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	return reportSaver().SaveAll(files)
+}
+
+// ReadReport 读取最近一次检测的 report.json，还没有报告时返回 method.ErrNotFound
+func ReadReport() ([]byte, error) {
+	return reportSaver().Get("report.json")
+}
+
+// withoutReport 去掉运行报告，回滚快照时报告不恢复到输出目录
+func withoutReport(files []utils.OutputFile) []utils.OutputFile {
+	return slices.DeleteFunc(slices.Clone(files), func(f utils.OutputFile) bool {
+		return slices.Contains(reportNames, f.Name)
+	})
+}
+
+// renderReport 生成 report.json，开启 report-csv 时同时生成 report.csv
+func renderReport(report *RunReport) ([]utils.OutputFile, error) {
+	if report == nil {
+		return nil, nil
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化运行报告失败: %w", err)
+	}
+	files := []utils.OutputFile{{Name: "report.json", Data: data}}
+
+	if config.GlobalConfig.ReportCSV {
+		data, err := reportCSV(report)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, utils.OutputFile{Name: "report.csv", Data: data})
+	}
+	return files, nil
+}
+
+// reportCSV 将报告中的节点转换为 CSV，平台之间用 | 分隔
+func reportCSV(report *RunReport) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"name", "type", "server", "port", "subscription", "passed", "reason",
+		"latency", "speed", "ip", "country", "risk", "platforms", "youtube", "tiktok"})
+	for _, node := range report.Nodes {
+		_ = w.Write([]string{
+			node.Name, node.Type, node.Server, node.Port, node.Subscription,
+			strconv.FormatBool(node.Passed), node.Reason,
+			strconv.Itoa(node.Latency), strconv.Itoa(node.Speed),
+			node.IP, node.Country, node.Risk,
+			strings.Join(node.Platforms, "|"), node.Youtube, node.TikTok,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("生成 CSV 失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
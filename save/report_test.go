@@ -0,0 +1,106 @@
+package save
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/beck-8/subs-check/check"
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/save/method"
+	"github.com/beck-8/subs-check/utils"
+)
+
+func TestRenderReport(t *testing.T) {
+	config.GlobalConfig.ReportCSV = true
+	t.Cleanup(func() { config.GlobalConfig.ReportCSV = false })
+
+	report := &RunReport{
+		Deduped:   2,
+		Available: 1,
+		Nodes: []NodeReport{
+			NewNodeReport(check.Result{
+				Proxy:   map[string]any{"name": "HK|NF", "type": "vmess", "server": "1.1.1.1", "port": 443},
+				SubURL:  "https://example.com/sub?token=secret",
+				Country: "hk",
+				Netflix: true,
+				Latency: 120,
+			}),
+			NewNodeReport(check.Result{
+				Proxy:   map[string]any{"name": "dead, node", "type": "ss", "server": "2.2.2.2", "port": "8388"},
+				SubTag:  "机场",
+				Failure: check.FailureTimeout,
+			}),
+		},
+	}
+
+	files, err := renderReport(report)
+	if err != nil {
+		t.Fatalf("renderReport() error = %v", err)
+	}
+	if len(files) != 2 || files[0].Name != "report.json" || files[1].Name != "report.csv" {
+		t.Fatalf("renderReport() files = %v", files)
+	}
+
+	var decoded RunReport
+	if err := json.Unmarshal(files[0].Data, &decoded); err != nil {
+		t.Fatalf("report.json 无法解析: %v", err)
+	}
+	first, second := decoded.Nodes[0], decoded.Nodes[1]
+	if !first.Passed || first.Subscription != "example.com" || first.Country != "HK" || first.Port != "443" || first.Platforms[0] != "netflix" {
+		t.Errorf("可用节点 = %+v", first)
+	}
+	if second.Passed || second.Reason != "dial-timeout" || second.Subscription != "机场" {
+		t.Errorf("失败节点 = %+v", second)
+	}
+	if strings.Contains(string(files[0].Data), "secret") {
+		t.Errorf("report.json 泄露了订阅 token")
+	}
+
+	csv := string(files[1].Data)
+	if !strings.HasPrefix(csv, "name,type,server,port,subscription,passed,reason,") {
+		t.Errorf("report.csv 表头 = %q", strings.SplitN(csv, "\n", 2)[0])
+	}
+	if !strings.Contains(csv, `"dead, node",ss,2.2.2.2,8388,机场,false,dial-timeout,`) {
+		t.Errorf("report.csv 缺少失败节点: %q", csv)
+	}
+}
+
+func TestSaveReport(t *testing.T) {
+	config.GlobalConfig.OutputDir = t.TempDir()
+	config.GlobalConfig.ReportDir = filepath.Join(t.TempDir(), "report")
+	t.Cleanup(func() {
+		config.GlobalConfig.OutputDir = ""
+		config.GlobalConfig.ReportDir = ""
+	})
+
+	if _, err := ReadReport(); !errors.Is(err, method.ErrNotFound) {
+		t.Errorf("ReadReport() without report error = %v, want ErrNotFound", err)
+	}
+
+	// 旧版本输出目录中的报告移动到报告目录
+	legacy := filepath.Join(config.GlobalConfig.OutputDir, "report.json")
+	if err := os.WriteFile(legacy, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ReadReport(); err != nil || string(data) != "old" {
+		t.Errorf("ReadReport() = %q, %v, want old", data, err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("输出目录中的旧报告没有被移走: %v", err)
+	}
+
+	// 新的报告只写入报告目录
+	if err := saveReport([]utils.OutputFile{{Name: "report.json", Data: []byte("new")}}); err != nil {
+		t.Fatalf("saveReport() error = %v", err)
+	}
+	if data, err := ReadReport(); err != nil || string(data) != "new" {
+		t.Errorf("ReadReport() = %q, %v, want new", data, err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("运行报告不应写入输出目录: %v", err)
+	}
+}
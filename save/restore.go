@@ -0,0 +1,111 @@
+package save
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/beck-8/subs-check/save/method"
+	"github.com/beck-8/subs-check/utils"
+)
+
+// RestoreOutputs 本地没有 node.yaml 时，从远程后端恢复上一次的输出
+// 按 save-method 的顺序尝试，第一个恢复成功的后端生效
+// 启动时在后台执行，恢复过程中已经保存了新的输出时放弃恢复
+func RestoreOutputs() {
+	local, err := method.NewLocalSaver()
+	if err != nil {
+		slog.Error(fmt.Sprintf("创建本地保存器失败: %v", err))
+		return
+	}
+	if hasLocalOutput(local) {
+		return
+	}
+
+	names := outputNames()
+	for _, backend := range chooseSaveBackends() {
+		if hasLocalOutput(local) {
+			return
+		}
+		restored, err := restoreFrom(backend, local, names)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("从%s恢复输出失败: %v", backend.Name(), err))
+			continue
+		}
+		if restored > 0 {
+			slog.Info(fmt.Sprintf("已从%s恢复上一次的输出", backend.Name()), "files", restored)
+			return
+		}
+	}
+}
+
+// restoreFrom 将后端中属于本程序输出的文件一次性写回本地
+func restoreFrom(backend method.Backend, local *method.LocalSaver, names []string) (int, error) {
+	remote, err := backend.List()
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(remote, "node.yaml") {
+		return 0, nil
+	}
+
+	var files []utils.OutputFile
+	for _, name := range names {
+		if !slices.Contains(remote, name) {
+			continue
+		}
+		data, err := backend.Get(name)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("从%s下载 %s 失败: %v", backend.Name(), name, err))
+			continue
+		}
+		files = append(files, utils.OutputFile{Name: name, Data: data})
+	}
+	if len(files) == 0 {
+		return 0, nil
+	}
+	// 下载期间检测已经完成并保存了新的输出，不再用旧的输出覆盖
+	if hasLocalOutput(local) {
+		return 0, nil
+	}
+	if err := local.SaveAll(files); err != nil {
+		return 0, err
+	}
+	return len(files), nil
+}
+
+// hasLocalOutput 本地是否已有输出
+func hasLocalOutput(local *method.LocalSaver) bool {
+	_, err := local.Get("node.yaml")
+	return !errors.Is(err, method.ErrNotFound)
+}
+
+func init() {
+	// 旧版本会把运行报告上传到远程后端，Gist 中的旧报告一并清理
+	method.GistArtifactNames = func() []string {
+		return append(outputNames(), reportNames...)
+	}
+}
+
+// outputNames 返回本程序会发布的所有文件名，不包括只保存在本地的运行报告
+func outputNames() []string {
+	names := []string{"node.yaml", "v2ray.txt", "stats.json"}
+	for _, tpl := range utils.RuleTemplates() {
+		names = append(names, tpl.Name)
+	}
+	for _, category := range profileCategories() {
+		names = append(names, category.Name)
+	}
+	return names
+}
+
+// reservedNames 内置输出使用的文件名，输出配置不能使用
+// 运行报告已经不在输出目录中，仍然保留其文件名，避免迁移旧版本的报告时移走输出配置的文件
+func reservedNames() []string {
+	names := []string{"node.yaml", "v2ray.txt", "stats.json", "report.json", "report.csv"}
+	for _, tpl := range utils.RuleTemplates() {
+		names = append(names, tpl.Name)
+	}
+	return names
+}
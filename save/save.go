@@ -1,12 +1,18 @@
 package save
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/beck-8/subs-check/check"
 	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/events"
+	"github.com/beck-8/subs-check/metrics"
 	"github.com/beck-8/subs-check/save/method"
 	"github.com/beck-8/subs-check/utils"
 	"gopkg.in/yaml.v3"
@@ -14,99 +20,256 @@ import (
 
 // ProxyCategory 定义代理分类
 type ProxyCategory struct {
-	Name    string
-	Proxies []map[string]any
-	Filter  func(result check.Result) bool
+	Name         string
+	Proxies      []map[string]any
+	Results      []check.Result
+	Filter       func(result check.Result) bool
+	Format       string // clash(默认)、singbox、v2ray
+	RuleTemplate string // 仅 clash 格式有效，为空时只输出节点
 }
 
 // ConfigSaver 处理配置保存的结构体
 type ConfigSaver struct {
 	results    []check.Result
 	categories []ProxyCategory
-	saveMethod func([]byte, string) error
+	backends   []method.Backend
+	message    string // 提交信息，为空时根据检测结果生成
 }
 
 // NewConfigSaver 创建新的配置保存器
 func NewConfigSaver(results []check.Result) *ConfigSaver {
 	return &ConfigSaver{
-		results:    results,
-		saveMethod: chooseSaveMethod(),
-		categories: []ProxyCategory{
+		results:  results,
+		backends: chooseSaveBackends(),
+		categories: append([]ProxyCategory{
 			{
 				Name:    "node.yaml",
 				Proxies: make([]map[string]any, 0),
 				Filter:  func(result check.Result) bool { return true },
 			},
-		},
+		}, profileCategories()...),
 	}
 }
 
-// SaveConfig 保存配置的入口函数
-func SaveConfig(results []check.Result) {
-	tmp := config.GlobalConfig.SaveMethod
-	config.GlobalConfig.SaveMethod = "local"
-	// 奇技淫巧，保存到本地一份，因为我没想道其他更好的方法同时保存
-	{
-		saver := NewConfigSaver(results)
-		if err := saver.Save(); err != nil {
-			slog.Error(fmt.Sprintf("保存配置失败: %v", err))
+// profileCategories 根据 output-profiles 配置生成分类
+// 名称必须是不含路径的文件名，不能与内置输出或其他输出配置重名，否则跳过该配置
+func profileCategories() []ProxyCategory {
+	var categories []ProxyCategory
+	used := make(map[string]bool)
+	for _, name := range reservedNames() {
+		used[name] = true
+	}
+	for _, profile := range config.GlobalConfig.OutputProfiles {
+		if err := validateProfileName(profile.Name, used); err != nil {
+			slog.Error(fmt.Sprintf("输出配置 %q 跳过: %v", profile.Name, err))
+			continue
+		}
+		filter, err := parseFilter(profile.Filter)
+		if err != nil {
+			slog.Error(fmt.Sprintf("输出配置 %s 筛选条件错误，跳过: %v", profile.Name, err))
+			continue
 		}
+		used[profile.Name] = true
+		categories = append(categories, ProxyCategory{
+			Name:         profile.Name,
+			Proxies:      make([]map[string]any, 0),
+			Filter:       filter,
+			Format:       profile.Format,
+			RuleTemplate: profile.RuleTemplate,
+		})
 	}
+	return categories
+}
 
-	if tmp == "local" {
-		return
+// validateProfileName 检查输出配置的文件名
+func validateProfileName(name string, used map[string]bool) error {
+	switch {
+	case name == "":
+		return errors.New("名称为空")
+	case filepath.Base(name) != name || name == "." || name == "..":
+		return errors.New("名称不能包含路径")
+	case used[name]:
+		return errors.New("与内置输出或其他输出配置重名")
 	}
-	config.GlobalConfig.SaveMethod = tmp
-	// 如果其他配置验证失败，还会保存到本地一次
-	{
-		saver := NewConfigSaver(results)
-		if err := saver.Save(); err != nil {
-			slog.Error(fmt.Sprintf("保存配置失败: %v", err))
-		}
+	return nil
+}
+
+// SaveConfig 保存配置的入口函数
+// 始终先保存到本地，再并发保存到配置的其他后端
+// 发布保护跳过保存时返回 ErrPublishBlocked，错误已经记录日志，调用方无需再次记录
+func SaveConfig(results []check.Result) error {
+	metrics.SetNodeStats(buildStats(results))
+
+	saver := NewConfigSaver(results)
+	err := saver.Save()
+	if errors.Is(err, ErrPublishBlocked) {
+		slog.Warn(fmt.Sprintf("%v，保留上一次的输出", err))
+		utils.SendWarning(fmt.Sprintf("%v，已保留上一次的输出", err))
+		events.Publish(events.SaveCompleted, events.SaveCompletedData{Nodes: len(results), Error: err.Error()})
+	} else if err != nil {
+		slog.Error(fmt.Sprintf("保存配置失败: %v", err))
+		events.Publish(events.SaveCompleted, events.SaveCompletedData{Nodes: len(results), Error: err.Error()})
 	}
+	return err
 }
 
 // Save 执行保存操作
 func (cs *ConfigSaver) Save() error {
+	report, err := renderReport(buildReport(cs.results))
+	if err != nil {
+		slog.Error(fmt.Sprintf("生成运行报告失败: %v", err))
+	}
+
+	// 跳过发布时也保存运行报告，方便排查节点失败的原因
+	if err := saveReport(report); err != nil {
+		slog.Error(fmt.Sprintf("保存运行报告失败: %v", err))
+	}
+
+	if err := checkPublishGuard(len(cs.results)); err != nil {
+		return err
+	}
+
 	// 分类处理代理
 	cs.categorizeProxies()
 
-	// 保存各个类别的代理
-	for _, category := range cs.categories {
-		if err := cs.saveCategory(category); err != nil {
-			slog.Error(fmt.Sprintf("保存到%s失败: %v", config.GlobalConfig.SaveMethod, err))
-			continue
-		}
+	// 先生成全部文件，再统一保存到本地和远程后端
+	files := cs.renderCategories()
+	files = append(files, cs.renderArtifacts(files)...)
+
+	var failed []string
+	if err := method.SaveAllToLocal(files); err != nil {
+		slog.Error(fmt.Sprintf("保存到local失败: %v", err))
+		metrics.SaveFailures.Inc("local")
+		failed = append(failed, "local")
 	}
 
-	saver, err := method.NewLocalSaver()
+	// 快照目录不公开，运行报告随快照保存，方便比较不同运行之间的变化
+	if err := saveSnapshot(append(slices.Clone(files), report...)); err != nil {
+		slog.Error(fmt.Sprintf("保存快照失败: %v", err))
+	}
 
-	if err != nil {
-		slog.Error(fmt.Sprintf("创建本地保存器失败: %v", err))
+	failed = append(failed, cs.saveToBackends(files)...)
+	events.Publish(events.SaveCompleted, events.SaveCompletedData{Nodes: len(cs.results), Files: len(files), Failed: failed})
+	return nil
+}
+
+// renderArtifacts 基于 node.yaml 生成 v2ray.txt、stats.json 和规则订阅
+// node.yaml 没有生成时跳过，避免与上一次的 node.yaml 不一致
+func (cs *ConfigSaver) renderArtifacts(files []utils.OutputFile) []utils.OutputFile {
+	idx := slices.IndexFunc(files, func(f utils.OutputFile) bool { return f.Name == "node.yaml" })
+	if idx == -1 {
+		slog.Warn("node.yaml 未生成，跳过生成其他订阅文件")
+		return nil
+	}
+	nodeContent := files[idx].Data
+
+	var artifacts []utils.OutputFile
+
+	// 生成转换订阅 V2ray
+	if data := utils.ConvertToV2Ray(cs.categories[0].Proxies); data != nil {
+		artifacts = append(artifacts, utils.OutputFile{Name: "v2ray.txt", Data: data})
+	}
+
+	stats := buildStats(cs.results)
+	if run := check.LastRun(); run != nil {
+		stats.Failures = run.Failures
+		stats.Partial = run.Partial
+	}
+
+	// 生成统计数据 JSON
+	if data, err := utils.GenerateStatsJSON(stats); err != nil {
+		slog.Error(fmt.Sprintf("生成统计数据失败: %v", err))
 	} else {
-		// 生成转换订阅 V2ray
-		if err := utils.ConvertToV2Ray(saver.OutputPath); err != nil {
-			slog.Error(fmt.Sprintf("转换 V2Ray 订阅失败: %v", err))
-		} else {
-			slog.Info("V2Ray 订阅转换成功", "filepath", filepath.Join(saver.OutputPath, "v2ray.txt"))
-		}
+		artifacts = append(artifacts, utils.OutputFile{Name: "stats.json", Data: data})
+		slog.Info("统计数据生成成功")
+	}
 
-		// 生成统计数据 JSON
-		if err := utils.GenerateStatsJSON(saver.OutputPath); err != nil {
-			slog.Error(fmt.Sprintf("生成统计数据失败: %v", err))
-		} else {
-			slog.Info("统计数据生成成功", "filepath", filepath.Join(saver.OutputPath, "stats.json"))
-		}
+	// 按规则模板生成 sub.yaml
+	subFiles, err := utils.GenerateSubYAML(stats, string(nodeContent))
+	if err != nil {
+		slog.Error(fmt.Sprintf("生成规则订阅失败: %v", err))
+	}
+	artifacts = append(artifacts, subFiles...)
 
-		// 生成 sub.yaml
-		if err := utils.GenerateSubYAML(saver.OutputPath); err != nil {
-			slog.Error(fmt.Sprintf("生成 sub.yaml 失败: %v", err))
-		} else {
-			slog.Info("sub.yaml 合并成功", "filepath", filepath.Join(saver.OutputPath, "sub.yaml"))
-		}
+	return artifacts
+}
+
+// saveToBackends 并发保存到所有远程后端，每个后端独立报告结果，返回保存失败的后端名称
+func (cs *ConfigSaver) saveToBackends(files []utils.OutputFile) []string {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []string
+	)
+	// 每个后端每次保存最多计一次失败，与文件数量无关
+	markFailed := func(name string) {
+		metrics.SaveFailures.Inc(name)
+		mu.Lock()
+		failed = append(failed, name)
+		mu.Unlock()
+	}
+	for _, backend := range cs.backends {
+		wg.Add(1)
+		go func(backend method.Backend) {
+			defer wg.Done()
+			if batch, ok := backend.(method.BatchBackend); ok {
+				if err := batch.PutAll(files, cs.commitMessage()); err != nil {
+					slog.Error(fmt.Sprintf("保存到%s失败: %v", backend.Name(), err))
+					markFailed(backend.Name())
+				} else {
+					slog.Info(fmt.Sprintf("保存到%s成功", backend.Name()), "files", len(files))
+				}
+				return
+			}
+			failedFiles := 0
+			for _, file := range files {
+				if err := backend.Put(file.Name, file.Data); err != nil {
+					slog.Error(fmt.Sprintf("保存到%s失败: %v", backend.Name(), err))
+					failedFiles++
+				}
+			}
+			if failedFiles > 0 {
+				slog.Error(fmt.Sprintf("保存到%s完成，%d/%d 个文件失败", backend.Name(), failedFiles, len(files)))
+				markFailed(backend.Name())
+			} else {
+				slog.Info(fmt.Sprintf("保存到%s成功", backend.Name()), "files", len(files))
+			}
+		}(backend)
 	}
+	wg.Wait()
+	slices.Sort(failed)
+	return failed
+}
 
-	return nil
+// commitMessage 生成本次运行的摘要，用于 git 等支持提交信息的后端
+func (cs *ConfigSaver) commitMessage() string {
+	if cs.message != "" {
+		return cs.message
+	}
+	stats := buildStats(cs.results)
+	message := fmt.Sprintf("更新订阅: 可用节点 %d", stats.TotalNodes)
+
+	countries := make([]string, 0, len(stats.Countries))
+	for country := range stats.Countries {
+		countries = append(countries, country)
+	}
+	slices.SortFunc(countries, func(a, b string) int {
+		if stats.Countries[a] != stats.Countries[b] {
+			return stats.Countries[b] - stats.Countries[a]
+		}
+		return strings.Compare(a, b)
+	})
+	if len(countries) > 5 {
+		countries = countries[:5]
+	}
+	if len(countries) > 0 {
+		top := make([]string, 0, len(countries))
+		for _, country := range countries {
+			top = append(top, fmt.Sprintf("%s %d", country, stats.Countries[country]))
+		}
+		message += "\n\n主要国家: " + strings.Join(top, ", ")
+	}
+	return message
 }
 
 // categorizeProxies 将代理按类别分类
@@ -115,62 +278,79 @@ func (cs *ConfigSaver) categorizeProxies() {
 		for i := range cs.categories {
 			if cs.categories[i].Filter(result) {
 				cs.categories[i].Proxies = append(cs.categories[i].Proxies, result.Proxy)
+				cs.categories[i].Results = append(cs.categories[i].Results, result)
 			}
 		}
 	}
 }
 
-// saveCategory 保存单个类别的代理
-func (cs *ConfigSaver) saveCategory(category ProxyCategory) error {
-	if len(category.Proxies) == 0 {
-		slog.Warn(fmt.Sprintf("yaml节点为空，跳过保存: %s, saveMethod: %s", category.Name, config.GlobalConfig.SaveMethod))
-		return nil
+// renderCategories 生成所有类别的文件内容，节点为空的类别会被跳过
+func (cs *ConfigSaver) renderCategories() []utils.OutputFile {
+	files := make([]utils.OutputFile, 0, len(cs.categories))
+	for _, category := range cs.categories {
+		if len(category.Proxies) == 0 {
+			slog.Warn(fmt.Sprintf("yaml节点为空，跳过保存: %s", category.Name))
+			continue
+		}
+		data, err := renderCategory(category)
+		if err != nil {
+			slog.Error(fmt.Sprintf("生成 %s 失败: %v", category.Name, err))
+			continue
+		}
+		files = append(files, utils.OutputFile{Name: category.Name, Data: data})
 	}
+	return files
+}
 
-	if category.Name == "node.yaml" {
+// renderCategory 按输出格式生成分类的文件内容
+func renderCategory(category ProxyCategory) ([]byte, error) {
+	switch category.Format {
+	case "", "clash":
 		yamlData, err := yaml.Marshal(map[string]any{
 			"proxies": category.Proxies,
 		})
 		if err != nil {
-			return fmt.Errorf("序列化yaml %s 失败: %w", category.Name, err)
+			return nil, fmt.Errorf("序列化yaml失败: %w", err)
 		}
-		if err := cs.saveMethod(yamlData, category.Name); err != nil {
-			return fmt.Errorf("保存 %s 失败: %w", category.Name, err)
+		if category.RuleTemplate == "" {
+			return yamlData, nil
 		}
-		return nil
+		tpl := config.RuleTemplate{Name: category.Name, Template: category.RuleTemplate}
+		return utils.RenderRuleTemplate(tpl, buildStats(category.Results), string(yamlData))
+	case "singbox":
+		return utils.ProxiesToSingBox(category.Proxies)
+	case "v2ray":
+		links := utils.ProxiesToV2RayLinks(category.Proxies)
+		if len(links) == 0 {
+			return nil, fmt.Errorf("没有可转换的 V2Ray 节点")
+		}
+		return []byte(strings.Join(links, "\n")), nil
+	default:
+		return nil, fmt.Errorf("未知的输出格式: %s", category.Format)
 	}
-
-	return nil
 }
 
-// chooseSaveMethod 根据配置选择保存方法
-func chooseSaveMethod() func([]byte, string) error {
-	switch config.GlobalConfig.SaveMethod {
-	case "r2":
-		if err := method.ValiR2Config(); err != nil {
-			return func(b []byte, s string) error { return fmt.Errorf("R2配置不完整: %v", err) }
-		}
-		return method.UploadToR2Storage
-	case "gist":
-		if err := method.ValiGistConfig(); err != nil {
-			return func(b []byte, s string) error { return fmt.Errorf("Gist配置不完整: %v", err) }
-		}
-		return method.UploadToGist
-	case "webdav":
-		if err := method.ValiWebDAVConfig(); err != nil {
-			return func(b []byte, s string) error { return fmt.Errorf("WebDAV配置不完整: %v", err) }
+// chooseSaveBackends 根据配置选择远程保存后端，本地保存始终执行，不在此列表中
+// 同类型的多个保存方法以 name 区分，名称重复时只使用第一个
+func chooseSaveBackends() []method.Backend {
+	var backends []method.Backend
+	seen := make(map[string]bool)
+	for _, m := range config.GlobalConfig.SaveMethod {
+		if m.Type == "local" {
+			continue
 		}
-		return method.UploadToWebDAV
-	case "local":
-		return method.SaveToLocal
-	case "s3": // New case for MinIO
-		if err := method.ValiS3Config(); err != nil {
-			return func(b []byte, s string) error { return fmt.Errorf("S3配置不完整: %v", err) }
+		name := m.Label()
+		if seen[name] {
+			slog.Warn(fmt.Sprintf("保存方法 %s 重复，配置多个同类型的保存方法时请填写不同的 name，已忽略", name))
+			continue
 		}
-		return method.UploadToS3
-	default:
-		return func(b []byte, s string) error {
-			return fmt.Errorf("未知的保存方法或其他方法配置错误: %v", config.GlobalConfig.SaveMethod)
+		seen[name] = true
+		backend, err := method.NewBackend(m)
+		if err != nil {
+			slog.Error(fmt.Sprintf("保存方法 %s 不可用，跳过: %v", name, err))
+			continue
 		}
+		backends = append(backends, backend)
 	}
+	return backends
 }
@@ -0,0 +1,97 @@
+package save
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/metrics"
+	"github.com/beck-8/subs-check/save/method"
+	"github.com/beck-8/subs-check/utils"
+)
+
+func TestProfileCategories(t *testing.T) {
+	saved := config.GlobalConfig.OutputProfiles
+	t.Cleanup(func() { config.GlobalConfig.OutputProfiles = saved })
+
+	config.GlobalConfig.OutputProfiles = []config.OutputProfile{
+		{Name: "jp.yaml", Filter: "country=JP"},
+		{Name: ""},
+		{Name: "../jp.yaml"},
+		{Name: "sub/jp.yaml"},
+		{Name: "stats.json"},
+		{Name: "sub.yaml"},
+		{Name: "jp.yaml", Filter: "country=HK"},
+		{Name: "bad.yaml", Filter: "speed>fast"},
+		{Name: "fast.txt", Filter: "speed>=1024", Format: "v2ray"},
+	}
+
+	var names []string
+	for _, category := range profileCategories() {
+		names = append(names, category.Name)
+	}
+	if want := []string{"jp.yaml", "fast.txt"}; !slices.Equal(names, want) {
+		t.Errorf("profileCategories() = %v, want %v", names, want)
+	}
+}
+
+// failingBackend 保存总是失败的后端
+type failingBackend struct{ name string }
+
+func (b failingBackend) Name() string               { return b.name }
+func (b failingBackend) Put(string, []byte) error   { return errors.New("保存失败") }
+func (b failingBackend) Get(string) ([]byte, error) { return nil, method.ErrNotFound }
+func (b failingBackend) List() ([]string, error)    { return nil, nil }
+func (b failingBackend) Delete(string) error        { return nil }
+
+func TestSaveToBackendsFailures(t *testing.T) {
+	saver := &ConfigSaver{backends: []method.Backend{failingBackend{"test-failing"}}}
+	files := []utils.OutputFile{
+		{Name: "node.yaml", Data: []byte("a")},
+		{Name: "stats.json", Data: []byte("b")},
+		{Name: "v2ray.txt", Data: []byte("c")},
+	}
+
+	// 多个文件失败时每次保存只计一次
+	for range 2 {
+		if failed := saver.saveToBackends(files); !slices.Equal(failed, []string{"test-failing"}) {
+			t.Errorf("saveToBackends() = %v, want [test-failing]", failed)
+		}
+	}
+	var buf bytes.Buffer
+	metrics.WriteTo(&buf)
+	if want := `subs_check_save_failures_total{backend="test-failing"} 2`; !strings.Contains(buf.String(), want) {
+		t.Errorf("metrics 中没有 %q", want)
+	}
+}
+
+func TestChooseSaveBackends(t *testing.T) {
+	saved := config.GlobalConfig.SaveMethod
+	t.Cleanup(func() { config.GlobalConfig.SaveMethod = saved })
+
+	webdav := func(name, url string) config.SaveMethod {
+		m := config.SaveMethod{Type: "webdav", Name: name}
+		m.WebDAVURL = url
+		m.WebDAVUsername = "user"
+		m.WebDAVPassword = "pass"
+		return m
+	}
+	config.GlobalConfig.SaveMethod = config.SaveMethods{
+		{Type: "local"},
+		webdav("nas", "http://nas/dav/"),
+		webdav("box", "http://box/dav/"),
+		webdav("nas", "http://other/dav/"),
+		{Type: "unknown"},
+	}
+
+	var names []string
+	for _, backend := range chooseSaveBackends() {
+		names = append(names, backend.Name())
+	}
+	if want := []string{"nas", "box"}; !slices.Equal(names, want) {
+		t.Errorf("chooseSaveBackends() = %v, want %v", names, want)
+	}
+}
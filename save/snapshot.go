@@ -0,0 +1,347 @@
+package save
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/metrics"
+	"github.com/beck-8/subs-check/save/method"
+	"github.com/beck-8/subs-check/utils"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	snapshotDirName = "snapshots"
+	// snapshotIDFmt 精确到毫秒，避免同一秒内多次保存时快照 ID 冲突
+	snapshotIDFmt = "20060102-150405.000"
+	// legacySnapshotIDFmt 旧版本精确到秒的快照 ID
+	legacySnapshotIDFmt = "20060102-150405"
+)
+
+// ErrSnapshotNotFound 快照不存在
+var ErrSnapshotNotFound = errors.New("快照不存在")
+
+// SnapshotInfo 快照概要
+type SnapshotInfo struct {
+	ID    string    `json:"id"`
+	Time  time.Time `json:"time"`
+	Nodes int       `json:"nodes"`
+	Files []string  `json:"files"`
+}
+
+// SnapshotDiff 两个快照之间的差异，节点按 类型+地址+端口 区分
+type SnapshotDiff struct {
+	From         string   `json:"from"`
+	To           string   `json:"to"`
+	Added        []string `json:"added"`
+	Removed      []string `json:"removed"`
+	ChangedFiles []string `json:"changed-files"`
+}
+
+// snapshotRoot 快照目录，默认位于配置目录下
+// 不放在输出目录中，因为输出目录会通过 /sub/ 公开访问
+func snapshotRoot() string {
+	root := config.GlobalConfig.SnapshotDir
+	if root == "" {
+		root = filepath.Join(utils.GetConfigDir(), snapshotDirName)
+	}
+	migrateLegacySnapshots(root)
+	return root
+}
+
+// migrateLegacySnapshots 将旧版本保存在输出目录中的快照移动到新的快照目录
+func migrateLegacySnapshots(root string) {
+	saver, err := method.NewLocalSaver()
+	if err != nil {
+		return
+	}
+	legacy := filepath.Join(saver.OutputPath, snapshotDirName)
+	if legacy == root {
+		return
+	}
+	if info, err := os.Stat(legacy); err != nil || !info.IsDir() {
+		return
+	}
+	if _, err := os.Stat(root); err == nil {
+		slog.Warn(fmt.Sprintf("输出目录中存在旧版本的快照，会被公开访问，请手动删除: %s", legacy))
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(root), 0755); err == nil {
+		err = os.Rename(legacy, root)
+		if err == nil {
+			slog.Info("已将快照移动到新的快照目录", "from", legacy, "to", root)
+			return
+		}
+	}
+	slog.Warn(fmt.Sprintf("移动旧版本的快照失败，输出目录中的快照会被公开访问，请手动删除: %s", legacy))
+}
+
+// parseSnapshotID 解析快照 ID 对应的时间，兼容旧版本精确到秒的 ID
+func parseSnapshotID(id string) (time.Time, bool) {
+	for _, layout := range []string{snapshotIDFmt, legacySnapshotIDFmt} {
+		if t, err := time.ParseInLocation(layout, id, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// snapshotDir 返回快照目录，并校验快照 ID
+func snapshotDir(id string) (string, error) {
+	if _, ok := parseSnapshotID(id); !ok {
+		return "", ErrSnapshotNotFound
+	}
+	root := snapshotRoot()
+	dir := filepath.Join(root, id)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", ErrSnapshotNotFound
+	}
+	return dir, nil
+}
+
+// saveSnapshot 将本次生成的文件保存为快照，并清理超出保留数量的旧快照
+func saveSnapshot(files []utils.OutputFile) error {
+	retention := config.GlobalConfig.SnapshotRetention
+	if retention <= 0 || len(files) == 0 {
+		return nil
+	}
+	root := snapshotRoot()
+
+	// 先写入临时目录，完整写入后再重命名，避免出现不完整的快照
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("创建快照目录失败: %w", err)
+	}
+	id := newSnapshotID(root)
+	temp, err := os.MkdirTemp(root, "."+id+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("创建快照目录失败: %w", err)
+	}
+	defer os.RemoveAll(temp)
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(temp, file.Name), file.Data, 0644); err != nil {
+			return fmt.Errorf("写入快照文件失败 [%s]: %w", file.Name, err)
+		}
+	}
+	if err := os.Chmod(temp, 0755); err != nil {
+		return fmt.Errorf("创建快照目录失败: %w", err)
+	}
+	if err := os.Rename(temp, filepath.Join(root, id)); err != nil {
+		return fmt.Errorf("保存快照失败: %w", err)
+	}
+	slog.Info("保存快照成功", "id", id)
+
+	return pruneSnapshots(root, retention)
+}
+
+// newSnapshotID 以当前时间生成快照 ID，与已有快照重复时顺延一毫秒
+func newSnapshotID(root string) string {
+	t := time.Now()
+	for {
+		id := t.Format(snapshotIDFmt)
+		if _, err := os.Stat(filepath.Join(root, id)); errors.Is(err, os.ErrNotExist) {
+			return id
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+// pruneSnapshots 只保留最新的 retention 个快照
+func pruneSnapshots(root string, retention int) error {
+	ids, err := snapshotIDs(root)
+	if err != nil {
+		return err
+	}
+	if len(ids) <= retention {
+		return nil
+	}
+	for _, id := range ids[retention:] {
+		if err := os.RemoveAll(filepath.Join(root, id)); err != nil {
+			return fmt.Errorf("删除旧快照失败 [%s]: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// snapshotIDs 返回所有快照 ID，最新的在前
+func snapshotIDs(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取快照目录失败: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if _, ok := parseSnapshotID(entry.Name()); entry.IsDir() && ok {
+			ids = append(ids, entry.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids, nil
+}
+
+// ListSnapshots 列出所有快照，最新的在前
+func ListSnapshots() ([]SnapshotInfo, error) {
+	root := snapshotRoot()
+	ids, err := snapshotIDs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]SnapshotInfo, 0, len(ids))
+	for _, id := range ids {
+		files, err := readSnapshot(filepath.Join(root, id))
+		if err != nil {
+			return nil, err
+		}
+		t, _ := parseSnapshotID(id)
+		info := SnapshotInfo{ID: id, Time: t, Files: make([]string, 0, len(files))}
+		for _, file := range files {
+			info.Files = append(info.Files, file.Name)
+			if file.Name == "node.yaml" {
+				info.Nodes = len(snapshotNodes(file.Data))
+			}
+		}
+		snapshots = append(snapshots, info)
+	}
+	return snapshots, nil
+}
+
+// readSnapshot 读取快照中的所有文件
+func readSnapshot(dir string) ([]utils.OutputFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取快照失败: %w", err)
+	}
+	var files []utils.OutputFile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("读取快照文件失败 [%s]: %w", entry.Name(), err)
+		}
+		files = append(files, utils.OutputFile{Name: entry.Name(), Data: data})
+	}
+	return files, nil
+}
+
+// snapshotNodes 解析 node.yaml，返回 节点标识 -> 节点名称
+func snapshotNodes(data []byte) map[string]string {
+	var content struct {
+		Proxies []map[string]any `yaml:"proxies"`
+	}
+	nodes := make(map[string]string)
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nodes
+	}
+	for _, proxy := range content.Proxies {
+		key := fmt.Sprintf("%v://%v:%v", proxy["type"], proxy["server"], proxy["port"])
+		nodes[key] = fmt.Sprint(proxy["name"])
+	}
+	return nodes
+}
+
+// DiffSnapshots 比较两个快照的节点和文件差异
+func DiffSnapshots(from, to string) (*SnapshotDiff, error) {
+	fromDir, err := snapshotDir(from)
+	if err != nil {
+		return nil, err
+	}
+	toDir, err := snapshotDir(to)
+	if err != nil {
+		return nil, err
+	}
+	fromFiles, err := readSnapshot(fromDir)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, err := readSnapshot(toDir)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SnapshotDiff{From: from, To: to, Added: []string{}, Removed: []string{}, ChangedFiles: []string{}}
+
+	fromData := make(map[string][]byte)
+	for _, file := range fromFiles {
+		fromData[file.Name] = file.Data
+	}
+	toData := make(map[string][]byte)
+	for _, file := range toFiles {
+		toData[file.Name] = file.Data
+		if old, ok := fromData[file.Name]; !ok || !bytes.Equal(old, file.Data) {
+			diff.ChangedFiles = append(diff.ChangedFiles, file.Name)
+		}
+	}
+	for name := range fromData {
+		if _, ok := toData[name]; !ok {
+			diff.ChangedFiles = append(diff.ChangedFiles, name)
+		}
+	}
+	slices.Sort(diff.ChangedFiles)
+
+	fromNodes := snapshotNodes(fromData["node.yaml"])
+	toNodes := snapshotNodes(toData["node.yaml"])
+	for key, name := range toNodes {
+		if _, ok := fromNodes[key]; !ok {
+			diff.Added = append(diff.Added, name)
+		}
+	}
+	for key, name := range fromNodes {
+		if _, ok := toNodes[key]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+
+	return diff, nil
+}
+
+// RollbackSnapshot 将快照恢复到输出目录，并重新发布到所有远程后端
+func RollbackSnapshot(id string) error {
+	dir, err := snapshotDir(id)
+	if err != nil {
+		return err
+	}
+	files, err := readSnapshot(dir)
+	if err != nil {
+		return err
+	}
+	// 运行报告只保存在快照和报告目录中，不恢复到公开的输出目录
+	files = withoutReport(files)
+	if len(files) == 0 {
+		return fmt.Errorf("快照为空: %s", id)
+	}
+
+	if err := method.SaveAllToLocal(files); err != nil {
+		metrics.SaveFailures.Inc("local")
+		return fmt.Errorf("保存到local失败: %w", err)
+	}
+
+	saver := &ConfigSaver{backends: chooseSaveBackends(), message: rollbackMessage(id, files)}
+	saver.saveToBackends(files)
+	slog.Info("已回滚到快照", "id", id)
+	return nil
+}
+
+// rollbackMessage 回滚的提交信息，节点数量取自快照中的 node.yaml
+func rollbackMessage(id string, files []utils.OutputFile) string {
+	message := fmt.Sprintf("回滚到快照 %s", id)
+	if idx := slices.IndexFunc(files, func(f utils.OutputFile) bool { return f.Name == "node.yaml" }); idx != -1 {
+		message += fmt.Sprintf(": 可用节点 %d", len(snapshotNodes(files[idx].Data)))
+	}
+	return message
+}
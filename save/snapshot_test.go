@@ -0,0 +1,142 @@
+package save
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/beck-8/subs-check/config"
+	"github.com/beck-8/subs-check/utils"
+)
+
+func writeSnapshot(t *testing.T, root, id string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(root, id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	config.GlobalConfig.OutputDir = t.TempDir()
+	config.GlobalConfig.SnapshotDir = t.TempDir()
+	t.Cleanup(func() {
+		config.GlobalConfig.OutputDir = ""
+		config.GlobalConfig.SnapshotDir = ""
+	})
+	root := config.GlobalConfig.SnapshotDir
+
+	writeSnapshot(t, root, "20250101-000000", map[string]string{
+		"node.yaml": "proxies:\n- {name: a, type: ss, server: 1.1.1.1, port: 1}\n- {name: b, type: ss, server: 2.2.2.2, port: 2}\n",
+		"v2ray.txt": "x",
+	})
+	writeSnapshot(t, root, "20250102-000000", map[string]string{
+		"node.yaml":   "proxies:\n- {name: a2, type: ss, server: 1.1.1.1, port: 1}\n- {name: c, type: vmess, server: 3.3.3.3, port: 3}\n",
+		"stats.json":  "{}",
+		"report.json": "{}",
+	})
+	writeSnapshot(t, root, "20250103-000000", map[string]string{"node.yaml": "proxies: []\n"})
+
+	diff, err := DiffSnapshots("20250101-000000", "20250102-000000")
+	if err != nil {
+		t.Fatalf("DiffSnapshots() error = %v", err)
+	}
+	if !slices.Equal(diff.Added, []string{"c"}) || !slices.Equal(diff.Removed, []string{"b"}) {
+		t.Errorf("DiffSnapshots() added = %v, removed = %v", diff.Added, diff.Removed)
+	}
+	if want := []string{"node.yaml", "report.json", "stats.json", "v2ray.txt"}; !slices.Equal(diff.ChangedFiles, want) {
+		t.Errorf("DiffSnapshots() changed files = %v, want %v", diff.ChangedFiles, want)
+	}
+
+	if _, err := DiffSnapshots("../../etc", "20250102-000000"); err != ErrSnapshotNotFound {
+		t.Errorf("DiffSnapshots() with invalid id error = %v, want ErrSnapshotNotFound", err)
+	}
+
+	if err := pruneSnapshots(root, 2); err != nil {
+		t.Fatalf("pruneSnapshots() error = %v", err)
+	}
+	snapshots, err := ListSnapshots()
+	if err != nil {
+		t.Fatalf("ListSnapshots() error = %v", err)
+	}
+	var ids []string
+	for _, s := range snapshots {
+		ids = append(ids, s.ID)
+	}
+	if want := []string{"20250103-000000", "20250102-000000"}; !slices.Equal(ids, want) {
+		t.Errorf("ListSnapshots() = %v, want %v", ids, want)
+	}
+	if snapshots[1].Nodes != 2 {
+		t.Errorf("ListSnapshots() nodes = %d, want 2", snapshots[1].Nodes)
+	}
+
+	if err := RollbackSnapshot("20250102-000000"); err != nil {
+		t.Fatalf("RollbackSnapshot() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.GlobalConfig.OutputDir, "stats.json")); err != nil {
+		t.Errorf("RollbackSnapshot() did not restore stats.json: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.GlobalConfig.OutputDir, "report.json")); !os.IsNotExist(err) {
+		t.Errorf("RollbackSnapshot() 不应将运行报告恢复到输出目录: %v", err)
+	}
+}
+
+func TestSaveSnapshotSameSecond(t *testing.T) {
+	config.GlobalConfig.OutputDir = t.TempDir()
+	config.GlobalConfig.SnapshotDir = t.TempDir()
+	retention := config.GlobalConfig.SnapshotRetention
+	config.GlobalConfig.SnapshotRetention = 10
+	t.Cleanup(func() {
+		config.GlobalConfig.OutputDir = ""
+		config.GlobalConfig.SnapshotDir = ""
+		config.GlobalConfig.SnapshotRetention = retention
+	})
+
+	files := []utils.OutputFile{{Name: "node.yaml", Data: []byte("proxies: []\n")}}
+	for range 3 {
+		if err := saveSnapshot(files); err != nil {
+			t.Fatalf("saveSnapshot() error = %v", err)
+		}
+	}
+	ids, err := snapshotIDs(config.GlobalConfig.SnapshotDir)
+	if err != nil || len(ids) != 3 {
+		t.Errorf("snapshotIDs() = %v, %v, want 3 个快照", ids, err)
+	}
+}
+
+func TestMigrateLegacySnapshots(t *testing.T) {
+	config.GlobalConfig.OutputDir = t.TempDir()
+	config.GlobalConfig.SnapshotDir = filepath.Join(t.TempDir(), "snapshots")
+	t.Cleanup(func() {
+		config.GlobalConfig.OutputDir = ""
+		config.GlobalConfig.SnapshotDir = ""
+	})
+	legacy := filepath.Join(config.GlobalConfig.OutputDir, snapshotDirName)
+	writeSnapshot(t, legacy, "20250101-000000", map[string]string{"node.yaml": "proxies: []\n"})
+
+	snapshots, err := ListSnapshots()
+	if err != nil || len(snapshots) != 1 || snapshots[0].ID != "20250101-000000" {
+		t.Fatalf("ListSnapshots() = %v, %v", snapshots, err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("输出目录中的旧快照没有被移走: %v", err)
+	}
+}
+
+func TestRollbackMessage(t *testing.T) {
+	files := []utils.OutputFile{
+		{Name: "node.yaml", Data: []byte("proxies:\n- {name: a, type: ss, server: 1.1.1.1, port: 1}\n- {name: b, type: ss, server: 2.2.2.2, port: 2}\n")},
+	}
+	if got, want := rollbackMessage("20250101-000000.000", files), "回滚到快照 20250101-000000.000: 可用节点 2"; got != want {
+		t.Errorf("rollbackMessage() = %q, want %q", got, want)
+	}
+	if got, want := rollbackMessage("20250101-000000.000", nil), "回滚到快照 20250101-000000.000"; got != want {
+		t.Errorf("rollbackMessage() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package save
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/beck-8/subs-check/check"
+	"github.com/beck-8/subs-check/utils"
+)
+
+// speedBuckets 测速分布区间(KB/s)，按上限升序
+var speedBuckets = []struct {
+	Name  string
+	Limit int
+}{
+	{"<1MB/s", 1024},
+	{"1-5MB/s", 5 * 1024},
+	{"5-10MB/s", 10 * 1024},
+	{"10-20MB/s", 20 * 1024},
+}
+
+// buildStats 根据检测结果生成统计数据
+func buildStats(results []check.Result) *utils.StatsData {
+	stats := utils.NewStatsData()
+
+	for _, result := range results {
+		stats.TotalNodes++
+
+		if result.Country != "" {
+			stats.Countries[strings.ToUpper(result.Country)]++
+		}
+
+		if proxyType, ok := result.Proxy["type"].(string); ok {
+			stats.Types[proxyType]++
+		}
+
+		for _, platform := range resultPlatforms(result) {
+			stats.Platforms[platform]++
+		}
+
+		if name := utils.SubscriptionName(result.SubURL, result.SubTag); name != "" {
+			stats.Subscriptions[name]++
+		}
+
+		if result.Speed > 0 {
+			stats.Speeds[speedBucket(result.Speed)]++
+		}
+
+		if bucket := riskBucket(result.IPRisk); bucket != "" {
+			stats.Risks[bucket]++
+		}
+	}
+
+	return stats
+}
+
+// resultPlatforms 返回节点解锁的平台列表
+func resultPlatforms(result check.Result) []string {
+	var platforms []string
+	if result.Openai {
+		platforms = append(platforms, "openai")
+	} else if result.OpenaiWeb {
+		platforms = append(platforms, "openai-web")
+	}
+	if result.Youtube != "" {
+		platforms = append(platforms, "youtube")
+	}
+	if result.Netflix {
+		platforms = append(platforms, "netflix")
+	}
+	if result.Disney {
+		platforms = append(platforms, "disney")
+	}
+	if result.Gemini {
+		platforms = append(platforms, "gemini")
+	}
+	if result.TikTok != "" {
+		platforms = append(platforms, "tiktok")
+	}
+	return platforms
+}
+
+// speedBucket 测速结果所在区间
+func speedBucket(speed int) string {
+	for _, bucket := range speedBuckets {
+		if speed < bucket.Limit {
+			return bucket.Name
+		}
+	}
+	return ">=20MB/s"
+}
+
+// riskBucket IP风险值所在区间，每25%为一档
+func riskBucket(risk string) string {
+	score, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(risk), "%"))
+	if err != nil {
+		return ""
+	}
+	switch {
+	case score < 25:
+		return "0-24%"
+	case score < 50:
+		return "25-49%"
+	case score < 75:
+		return "50-74%"
+	default:
+		return "75-100%"
+	}
+}
@@ -3,12 +3,8 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
-	"regexp"
 
 	"github.com/beck-8/subs-check/config"
-	"gopkg.in/yaml.v3"
 )
 
 // StatsData 统计数据结构
@@ -16,63 +12,76 @@ type StatsData struct {
 	TotalNodes        int            `json:"nodes"`
 	Countries         map[string]int `json:"countries"`
 	Types             map[string]int `json:"types"`
+	Platforms         map[string]int `json:"platforms"`
+	Subscriptions     map[string]int `json:"subscriptions"`
+	Speeds            map[string]int `json:"speeds"`
+	Risks             map[string]int `json:"risks"`
 	V2RaySubscription bool           `json:"v2ray-subscription"`
 	MediaCheck        bool           `json:"media-check"`
+	Failures          *FailureStats  `json:"failures,omitempty"`
+	Partial           bool           `json:"partial,omitempty"` // 达到最大运行时长或被取消，只包含部分节点的结果
 }
 
-// ProxiesYAML 用于解析 node.yaml 的结构
-type ProxiesYAML struct {
-	Proxies []map[string]interface{} `json:"proxies" yaml:"proxies"`
+// FailureStats 失败节点的原因统计，按原因、订阅和协议汇总
+type FailureStats struct {
+	Total         int                       `json:"total"`
+	Reasons       map[string]int            `json:"reasons"`
+	Subscriptions map[string]map[string]int `json:"subscriptions"`
+	Protocols     map[string]map[string]int `json:"protocols"`
 }
 
-// GenerateStatsJSON 生成统计数据 JSON 文件
-func GenerateStatsJSON(outputPath string) error {
-	yamlPath := filepath.Join(outputPath, "node.yaml")
-	yamlData, err := os.ReadFile(yamlPath)
-	if err != nil {
-		return fmt.Errorf("读取 node.yaml 失败: %w", err)
-	}
-
-	var proxiesData ProxiesYAML
-	if err = yaml.Unmarshal(yamlData, &proxiesData); err != nil {
-		return fmt.Errorf("解析 YAML 失败: %w", err)
-	}
-
-	stats := StatsData{
-		Countries: make(map[string]int),
-		Types:     make(map[string]int),
+// NewFailureStats 创建空的失败统计
+func NewFailureStats() *FailureStats {
+	return &FailureStats{
+		Reasons:       make(map[string]int),
+		Subscriptions: make(map[string]map[string]int),
+		Protocols:     make(map[string]map[string]int),
 	}
+}
 
-	countryRegex := regexp.MustCompile(`^([\x{1F1E6}-\x{1F1FF}]{2})([A-Z]{2})`)
-
-	for _, proxy := range proxiesData.Proxies {
-		stats.TotalNodes++
-
-		if name, ok := proxy["name"].(string); ok {
-			matches := countryRegex.FindStringSubmatch(name)
-			if len(matches) > 2 {
-				countryCode := matches[2]
-				stats.Countries[countryCode]++
-			}
+// Add 记录一个失败节点
+func (f *FailureStats) Add(subscription, protocol, reason string) {
+	f.Total++
+	f.Reasons[reason]++
+	if subscription != "" {
+		if f.Subscriptions[subscription] == nil {
+			f.Subscriptions[subscription] = make(map[string]int)
 		}
-
-		if proxyType, ok := proxy["type"].(string); ok {
-			stats.Types[proxyType]++
+		f.Subscriptions[subscription][reason]++
+	}
+	if protocol != "" {
+		if f.Protocols[protocol] == nil {
+			f.Protocols[protocol] = make(map[string]int)
 		}
+		f.Protocols[protocol][reason]++
 	}
+}
 
-	stats.V2RaySubscription = config.GlobalConfig.V2RaySubscription
-	stats.MediaCheck = config.GlobalConfig.MediaCheck
+// NewStatsData 创建空的统计数据
+func NewStatsData() *StatsData {
+	return &StatsData{
+		Countries:         make(map[string]int),
+		Types:             make(map[string]int),
+		Platforms:         make(map[string]int),
+		Subscriptions:     make(map[string]int),
+		Speeds:            make(map[string]int),
+		Risks:             make(map[string]int),
+		V2RaySubscription: config.GlobalConfig.V2RaySubscription,
+		MediaCheck:        config.GlobalConfig.MediaCheck,
+	}
+}
 
+// GenerateStatsJSON 生成统计数据 JSON
+func GenerateStatsJSON(stats *StatsData) ([]byte, error) {
 	jsonData, err := json.MarshalIndent(stats, "", "  ")
 	if err != nil {
-		return fmt.Errorf("序列化 JSON 失败: %w", err)
-	}
-
-	jsonPath := filepath.Join(outputPath, "stats.json")
-	if err := os.WriteFile(jsonPath, jsonData, 0644); err != nil {
-		return fmt.Errorf("保存 stats.json 失败: %w", err)
+		return nil, fmt.Errorf("序列化 JSON 失败: %w", err)
 	}
+	return jsonData, nil
+}
 
-	return nil
+// OutputFile 生成的输出文件
+type OutputFile struct {
+	Name string
+	Data []byte
 }
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+// FetchWithRetry 按订阅的重试、间隔和超时配置下载远程数据，ctx 取消时立即返回
+func FetchWithRetry(ctx context.Context, url string) ([]byte, error) {
+	maxRetries := config.GlobalConfig.SubUrlsReTry
+	// 重试间隔
+	retryInterval := config.GlobalConfig.SubUrlsRetryInterval
+	if retryInterval == 0 {
+		retryInterval = 1
+	}
+	// 超时时间
+	timeout := config.GlobalConfig.SubUrlsTimeout
+	if timeout == 0 {
+		timeout = 10
+	}
+	var lastErr error
+
+	client := &http.Client{
+		Timeout: time.Duration(timeout) * time.Second,
+	}
+
+	for i := 0; i < maxRetries; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(time.Duration(retryInterval) * time.Second):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		body, err := fetchOnce(ctx, client, url)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+		return body, nil
+	}
+
+	return nil, fmt.Errorf("重试%d次后失败: %v", maxRetries, lastErr)
+}
+
+// fetchOnce 执行单次下载
+func fetchOnce(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", "clash.meta")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("订阅链接: %s 返回状态码: %d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取订阅链接: %s 数据错误: %v", url, err)
+	}
+	return body, nil
+}
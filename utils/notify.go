@@ -45,6 +45,20 @@ func Notify(request NotifyRequest) error {
 }
 
 func SendNotify(length int) {
+	sendNotify(fmt.Sprintf("✅ 可用节点：%d\n🕒 %s",
+		length,
+		GetCurrentTime()))
+}
+
+// SendWarning 发送告警通知
+func SendWarning(message string) {
+	sendNotify(fmt.Sprintf("⚠️ %s\n🕒 %s",
+		message,
+		GetCurrentTime()))
+}
+
+// sendNotify 向所有通知目标发送消息
+func sendNotify(body string) {
 	if config.GlobalConfig.AppriseApiServer == "" {
 		return
 	} else if len(config.GlobalConfig.RecipientUrl) == 0 {
@@ -54,10 +68,8 @@ func SendNotify(length int) {
 
 	for _, url := range config.GlobalConfig.RecipientUrl {
 		request := NotifyRequest{
-			URLs: url,
-			Body: fmt.Sprintf("✅ 可用节点：%d\n🕒 %s",
-				length,
-				GetCurrentTime()),
+			URLs:  url,
+			Body:  body,
 			Title: config.GlobalConfig.NotifyTitle,
 		}
 		var err error
@@ -5,7 +5,6 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
-	"sync/atomic"
 	"syscall"
 )
 
@@ -13,10 +12,8 @@ import (
 // var ctrlCOccurred atomic.Bool
 
 // SetupSignalHandler 设置信号处理
-// 同时支持两种信号处理模式：
-// - HUB 信号(SIGHUP): 只设置 check.ForceClose 为 true，不退出程序
-// - Ctrl+C 信号(SIGINT/SIGTERM): 第一次设置 ForceClose，第二次退出程序
-func SetupSignalHandler(forceClose *atomic.Bool) {
+// HUB 信号(SIGHUP): 调用 onHUP 取消正在进行的检测，不退出程序
+func SetupSignalHandler(onHUP func()) {
 	slog.Debug("设置信号处理器")
 
 	// 监听 SIGINT (Ctrl+C)
@@ -55,9 +52,9 @@ func SetupSignalHandler(forceClose *atomic.Bool) {
 		for sig := range hubSigChan {
 			slog.Debug(fmt.Sprintf("收到 HUB 信号: %s", sig))
 
-			// HUB 信号只设置 ForceClose，不退出程序
-			forceClose.Store(true)
-			slog.Debug("HUB 模式: 已设置强制关闭标志，任务将自动结束，程序继续运行")
+			// HUB 信号只取消检测，不退出程序
+			onHUP()
+			slog.Debug("HUB 模式: 已取消正在进行的检测，程序继续运行")
 		}
 	}()
 }
@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// ProxiesToSingBox 将节点转换为 sing-box 的 outbounds 配置，不支持的协议会被跳过
+func ProxiesToSingBox(proxies []map[string]any) ([]byte, error) {
+	outbounds := make([]map[string]any, 0, len(proxies))
+	for _, proxy := range proxies {
+		proxyType, _ := proxy["type"].(string)
+
+		var outbound map[string]any
+		switch proxyType {
+		case "ss", "shadowsocks":
+			outbound = convertShadowsocksToSingBox(proxy)
+		case "vmess":
+			outbound = convertVMessToSingBox(proxy)
+		case "vless":
+			outbound = convertVLESSToSingBox(proxy)
+		case "trojan":
+			outbound = convertTrojanToSingBox(proxy)
+		case "hysteria2", "hy2":
+			outbound = convertHysteria2ToSingBox(proxy)
+		}
+
+		if outbound != nil {
+			outbounds = append(outbounds, outbound)
+		}
+	}
+
+	data, err := json.MarshalIndent(map[string]any{"outbounds": outbounds}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化 sing-box 配置失败: %w", err)
+	}
+	return data, nil
+}
+
+// newSingBoxOutbound 创建 outbound 的公共字段，服务器或端口缺失时返回 nil
+func newSingBoxOutbound(outboundType string, proxy map[string]any) map[string]any {
+	server := getString(proxy, "server")
+	port := getPort(proxy)
+	if server == "" || port == 0 {
+		return nil
+	}
+	return map[string]any{
+		"type":        outboundType,
+		"tag":         getString(proxy, "name"),
+		"server":      server,
+		"server_port": port,
+	}
+}
+
+// convertShadowsocksToSingBox 转换 Shadowsocks 为 sing-box 格式
+func convertShadowsocksToSingBox(proxy map[string]any) map[string]any {
+	password := getString(proxy, "password")
+	outbound := newSingBoxOutbound("shadowsocks", proxy)
+	if outbound == nil || password == "" {
+		return nil
+	}
+
+	cipher := getString(proxy, "cipher")
+	if cipher == "" {
+		cipher = "aes-256-gcm"
+	}
+	outbound["method"] = cipher
+	outbound["password"] = password
+	return outbound
+}
+
+// convertVMessToSingBox 转换 VMess 为 sing-box 格式
+func convertVMessToSingBox(proxy map[string]any) map[string]any {
+	uuid := getString(proxy, "uuid")
+	outbound := newSingBoxOutbound("vmess", proxy)
+	if outbound == nil || uuid == "" {
+		return nil
+	}
+
+	cipher := getString(proxy, "cipher")
+	if cipher == "" {
+		cipher = "auto"
+	}
+	outbound["uuid"] = uuid
+	outbound["security"] = cipher
+	outbound["alter_id"] = getInt(proxy, "alterId")
+	if getBool(proxy, "tls") {
+		outbound["tls"] = singBoxTLS(proxy)
+	}
+	if transport := singBoxTransport(proxy); transport != nil {
+		outbound["transport"] = transport
+	}
+	return outbound
+}
+
+// convertVLESSToSingBox 转换 VLESS 为 sing-box 格式
+func convertVLESSToSingBox(proxy map[string]any) map[string]any {
+	uuid := getString(proxy, "uuid")
+	outbound := newSingBoxOutbound("vless", proxy)
+	if outbound == nil || uuid == "" {
+		return nil
+	}
+
+	outbound["uuid"] = uuid
+	if flow := getString(proxy, "flow"); flow != "" {
+		outbound["flow"] = flow
+	}
+	if getBool(proxy, "tls") {
+		outbound["tls"] = singBoxTLS(proxy)
+	}
+	if transport := singBoxTransport(proxy); transport != nil {
+		outbound["transport"] = transport
+	}
+	return outbound
+}
+
+// convertTrojanToSingBox 转换 Trojan 为 sing-box 格式
+func convertTrojanToSingBox(proxy map[string]any) map[string]any {
+	password := getString(proxy, "password")
+	outbound := newSingBoxOutbound("trojan", proxy)
+	if outbound == nil || password == "" {
+		return nil
+	}
+
+	outbound["password"] = password
+	outbound["tls"] = singBoxTLS(proxy)
+	if transport := singBoxTransport(proxy); transport != nil {
+		outbound["transport"] = transport
+	}
+	return outbound
+}
+
+// convertHysteria2ToSingBox 转换 Hysteria2 为 sing-box 格式
+func convertHysteria2ToSingBox(proxy map[string]any) map[string]any {
+	password := getString(proxy, "password")
+	outbound := newSingBoxOutbound("hysteria2", proxy)
+	if outbound == nil || password == "" {
+		return nil
+	}
+
+	outbound["password"] = password
+	if obfs := getString(proxy, "obfs"); obfs != "" {
+		outbound["obfs"] = map[string]any{
+			"type":     obfs,
+			"password": getString(proxy, "obfs-password"),
+		}
+	}
+	outbound["tls"] = singBoxTLS(proxy)
+	return outbound
+}
+
+// singBoxTLS 生成 tls 配置
+func singBoxTLS(proxy map[string]any) map[string]any {
+	tls := map[string]any{"enabled": true}
+	if sni := getString(proxy, "servername", "sni"); sni != "" {
+		tls["server_name"] = sni
+	}
+	if getBool(proxy, "skip-cert-verify") {
+		tls["insecure"] = true
+	}
+	if alpn, ok := proxy["alpn"].([]any); ok && len(alpn) > 0 {
+		tls["alpn"] = alpn
+	}
+	if fp := getString(proxy, "client-fingerprint"); fp != "" {
+		tls["utls"] = map[string]any{"enabled": true, "fingerprint": fp}
+	}
+	if publicKey := getNestedString(proxy, "reality-opts", "public-key"); publicKey != "" {
+		tls["reality"] = map[string]any{
+			"enabled":    true,
+			"public_key": publicKey,
+			"short_id":   getNestedString(proxy, "reality-opts", "short-id"),
+		}
+	}
+	return tls
+}
+
+// singBoxTransport 生成传输层配置，tcp 时返回 nil
+func singBoxTransport(proxy map[string]any) map[string]any {
+	switch getString(proxy, "network") {
+	case "ws":
+		transport := map[string]any{"type": "ws"}
+		if path := getNestedString(proxy, "ws-opts", "path"); path != "" {
+			transport["path"] = path
+		}
+		if host := getNestedString(proxy, "ws-opts", "headers", "Host"); host != "" {
+			transport["headers"] = map[string]any{"Host": host}
+		}
+		return transport
+	case "grpc":
+		return map[string]any{
+			"type":         "grpc",
+			"service_name": getNestedString(proxy, "grpc-opts", "grpc-service-name"),
+		}
+	}
+	return nil
+}
+
+// getPort 获取端口，兼容 yaml 与 json 解析出的不同类型
+func getPort(m map[string]any) int {
+	switch v := m["port"].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case uint16:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		port, _ := strconv.Atoi(v)
+		return port
+	}
+	return 0
+}
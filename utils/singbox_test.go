@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestProxiesToSingBox(t *testing.T) {
+	tests := []struct {
+		name  string
+		proxy map[string]any
+		want  map[string]any // nil 表示节点被跳过
+	}{
+		{
+			name:  "ss 默认加密方式",
+			proxy: map[string]any{"name": "ss", "type": "ss", "server": "1.1.1.1", "port": 8388, "password": "pwd"},
+			want: map[string]any{
+				"type": "shadowsocks", "tag": "ss", "server": "1.1.1.1", "server_port": float64(8388),
+				"method": "aes-256-gcm", "password": "pwd",
+			},
+		},
+		{
+			name: "vless tls ws",
+			proxy: map[string]any{
+				"name": "vless", "type": "vless", "server": "example.com", "port": "443", "uuid": "id",
+				"tls": true, "servername": "sni.example.com", "network": "ws",
+				"ws-opts": map[string]any{"path": "/ws", "headers": map[string]any{"Host": "host.example.com"}},
+			},
+			want: map[string]any{
+				"type": "vless", "tag": "vless", "server": "example.com", "server_port": float64(443), "uuid": "id",
+				"tls":       map[string]any{"enabled": true, "server_name": "sni.example.com"},
+				"transport": map[string]any{"type": "ws", "path": "/ws", "headers": map[string]any{"Host": "host.example.com"}},
+			},
+		},
+		{
+			name: "trojan grpc",
+			proxy: map[string]any{
+				"name": "trojan", "type": "trojan", "server": "2.2.2.2", "port": 443, "password": "pwd",
+				"skip-cert-verify": true, "network": "grpc", "grpc-opts": map[string]any{"grpc-service-name": "svc"},
+			},
+			want: map[string]any{
+				"type": "trojan", "tag": "trojan", "server": "2.2.2.2", "server_port": float64(443), "password": "pwd",
+				"tls":       map[string]any{"enabled": true, "insecure": true},
+				"transport": map[string]any{"type": "grpc", "service_name": "svc"},
+			},
+		},
+		{
+			name:  "不支持的协议",
+			proxy: map[string]any{"name": "ssr", "type": "ssr", "server": "1.1.1.1", "port": 443, "password": "pwd"},
+		},
+		{
+			name:  "缺少密码",
+			proxy: map[string]any{"name": "ss", "type": "ss", "server": "1.1.1.1", "port": 8388},
+		},
+		{
+			name:  "缺少端口",
+			proxy: map[string]any{"name": "hy2", "type": "hysteria2", "server": "1.1.1.1", "password": "pwd"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ProxiesToSingBox([]map[string]any{tt.proxy})
+			if err != nil {
+				t.Fatalf("ProxiesToSingBox() error = %v", err)
+			}
+			var got struct {
+				Outbounds []map[string]any `json:"outbounds"`
+			}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("输出不是有效的 JSON: %v", err)
+			}
+			if tt.want == nil {
+				if len(got.Outbounds) != 0 {
+					t.Errorf("outbounds = %v, want 跳过", got.Outbounds)
+				}
+				return
+			}
+			if len(got.Outbounds) != 1 {
+				t.Fatalf("outbounds 数量 = %d, want 1", len(got.Outbounds))
+			}
+			if !reflect.DeepEqual(got.Outbounds[0], tt.want) {
+				t.Errorf("outbound = %v, want %v", got.Outbounds[0], tt.want)
+			}
+		})
+	}
+}
@@ -1,22 +1,29 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/beck-8/subs-check/config"
-	"gopkg.in/yaml.v3"
 )
 
 // CountryInfo 国家信息结构
 type CountryInfo struct {
-	Code   string `json:"code"`
-	Flag   string `json:"flag"`
-	CnName string `json:"cn-name"`
-	EnName string `json:"en-name"`
+	Code      string   `json:"code"`
+	Flag      string   `json:"flag"`
+	CnName    string   `json:"cn-name"`
+	EnName    string   `json:"en-name"`
+	Continent string   `json:"continent"`
+	Sets      []string `json:"sets,omitempty"` // 所属的自定义国家组
 }
 
 // ConfigData 配置数据结构
@@ -25,71 +32,103 @@ type ConfigData struct {
 	Platforms  []string `yaml:"platforms"`
 }
 
-// GenerateSubYAML 生成 sub.yaml 文件
-func GenerateSubYAML(outputPath string) error {
-	// 读取 rule.yaml
-	rulePath := filepath.Join(outputPath, "..", "config", "rule.yaml")
-	ruleContent, err := os.ReadFile(rulePath)
-	if err != nil {
-		return fmt.Errorf("读取 rule.yaml 失败: %w", err)
+// GenerateSubYAML 按规则模板生成 sub.yaml 等订阅文件
+// 未配置 rule-templates 时只使用 rule-template 生成 sub.yaml
+// 单个模板失败不影响其他模板，返回生成成功的文件
+func GenerateSubYAML(statsData *StatsData, nodeContent string) ([]OutputFile, error) {
+	var files []OutputFile
+	var failed int
+	for _, tpl := range RuleTemplates() {
+		if tpl.Name == "" || filepath.Base(tpl.Name) != tpl.Name {
+			slog.Error(fmt.Sprintf("规则模板输出文件名不合法: %q", tpl.Name))
+			failed++
+			continue
+		}
+		subContent, err := RenderRuleTemplate(tpl, statsData, nodeContent)
+		if err != nil {
+			slog.Error(fmt.Sprintf("生成 %s 失败: %v", tpl.Name, err))
+			failed++
+			continue
+		}
+		slog.Info(fmt.Sprintf("%s 合并成功", tpl.Name))
+		files = append(files, OutputFile{Name: tpl.Name, Data: subContent})
+	}
+	if failed > 0 {
+		return files, fmt.Errorf("%d 个规则模板生成失败", failed)
 	}
 
-	// 读取 stats.json
-	statsPath := filepath.Join(outputPath, "stats.json")
-	statsData, err := readStatsData(statsPath)
-	if err != nil {
-		return fmt.Errorf("读取 stats.json 失败: %w", err)
+	return files, nil
+}
+
+// RuleTemplates 返回需要生成的规则模板列表
+func RuleTemplates() []config.RuleTemplate {
+	if len(config.GlobalConfig.RuleTemplates) > 0 {
+		return config.GlobalConfig.RuleTemplates
+	}
+	return []config.RuleTemplate{
+		{Name: "sub.yaml", Template: config.GlobalConfig.RuleTemplate},
 	}
+}
 
+// RenderRuleTemplate 将规则模板与节点合并为完整的订阅内容
+func RenderRuleTemplate(tpl config.RuleTemplate, statsData *StatsData, nodeContent string) ([]byte, error) {
 	// 读取 countries.json
-	countriesPath := filepath.Join(outputPath, "..", "config", "countries.json")
+	countriesPath := filepath.Join(GetConfigDir(), "countries.json")
 	countriesMap, err := readCountriesMap(countriesPath)
 	if err != nil {
-		return fmt.Errorf("读取 countries.json 失败: %w", err)
+		return nil, fmt.Errorf("读取 countries.json 失败: %w", err)
 	}
 
-	// 读取 config.yaml
-	configPath := filepath.Join(outputPath, "..", "config", "config.yaml")
-	configData, err := readConfigData(configPath)
-	if err != nil {
-		return fmt.Errorf("读取 config.yaml 失败: %w", err)
+	configData := &ConfigData{
+		MediaCheck: config.GlobalConfig.MediaCheck,
+		Platforms:  config.GlobalConfig.Platforms,
 	}
 
-	// 读取 node.yaml
-	nodePath := filepath.Join(outputPath, "node.yaml")
-	nodeContent, err := os.ReadFile(nodePath)
+	ruleContent, err := loadRuleTemplate(tpl)
 	if err != nil {
-		return fmt.Errorf("读取 node.yaml 失败: %w", err)
+		return nil, fmt.Errorf("读取规则模板失败: %w", err)
 	}
 
-	// 处理生成 sub.yaml
-	subContent, err := processRuleContent(string(ruleContent), statsData, countriesMap, configData, string(nodeContent))
+	subContent, err := processRuleContent(string(ruleContent), statsData, countriesMap, configData, nodeContent)
 	if err != nil {
-		return fmt.Errorf("处理 rule.yaml 失败: %w", err)
+		return nil, fmt.Errorf("处理规则模板失败: %w", err)
 	}
+	return []byte(subContent), nil
+}
 
-	// 写入 sub.yaml
-	subPath := filepath.Join(outputPath, "sub.yaml")
-	if err := os.WriteFile(subPath, []byte(subContent), 0644); err != nil {
-		return fmt.Errorf("保存 sub.yaml 失败: %w", err)
+// loadRuleTemplate 读取规则模板，支持本地路径和远程链接
+// 为空时使用配置目录下的 rule.yaml，相对路径以配置目录为基准
+// 远程模板下载成功后缓存到本地，下载失败时使用上一次的缓存
+func loadRuleTemplate(tpl config.RuleTemplate) ([]byte, error) {
+	source := strings.TrimSpace(tpl.Template)
+	if source == "" {
+		return os.ReadFile(filepath.Join(GetConfigDir(), "rule.yaml"))
 	}
 
-	return nil
-}
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		if !filepath.IsAbs(source) {
+			source = filepath.Join(GetConfigDir(), source)
+		}
+		return os.ReadFile(source)
+	}
 
-// readStatsData 读取统计数据
-func readStatsData(path string) (*StatsData, error) {
-	data, err := os.ReadFile(path)
+	cachePath := filepath.Join(GetConfigDir(), "cache", "rule-"+tpl.Name)
+	data, err := FetchWithRetry(context.Background(), WarpUrl(source))
 	if err != nil {
-		return nil, err
+		cached, cacheErr := os.ReadFile(cachePath)
+		if cacheErr != nil {
+			return nil, fmt.Errorf("下载远程规则模板失败且没有缓存: %w", err)
+		}
+		slog.Warn(fmt.Sprintf("下载远程规则模板失败，使用缓存: %v", err), "url", source)
+		return cached, nil
 	}
 
-	var stats StatsData
-	if err := yaml.Unmarshal(data, &stats); err != nil {
-		return nil, err
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		slog.Warn(fmt.Sprintf("创建规则模板缓存目录失败: %v", err))
+	} else if err := os.WriteFile(cachePath, data, 0644); err != nil {
+		slog.Warn(fmt.Sprintf("缓存远程规则模板失败: %v", err))
 	}
-
-	return &stats, nil
+	return data, nil
 }
 
 // readCountriesMap 读取国家映射
@@ -116,22 +155,18 @@ func readCountriesMap(path string) (map[string]CountryInfo, error) {
 		countriesMap[country.Code] = country
 	}
 
-	return countriesMap, nil
-}
-
-// readConfigData 读取配置数据
-func readConfigData(path string) (*ConfigData, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	var config ConfigData
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, err
+	// 旧版本的 countries.json 没有大洲信息，从内置模板补全
+	var defaults []CountryInfo
+	if err := json.Unmarshal(config.DefaultCountriesTemplate, &defaults); err == nil {
+		for _, def := range defaults {
+			if country, ok := countriesMap[def.Code]; ok && country.Continent == "" {
+				country.Continent = def.Continent
+				countriesMap[def.Code] = country
+			}
+		}
 	}
 
-	return &config, nil
+	return countriesMap, nil
 }
 
 // processRuleContent 处理 rule.yaml 内容
@@ -140,13 +175,17 @@ func processRuleContent(ruleContent string, statsData *StatsData, countriesMap m
 	var result []string
 	i := 0
 
+	groups := buildCountryGroups(statsData.Countries, countriesMap)
+	continents := buildContinentGroups(statsData.Countries, countriesMap)
+	sets := buildCountrySets(statsData.Countries, countriesMap)
+
 	for i < len(lines) {
 		line := lines[i]
 
 		// 处理 {countries.name.list}
 		if strings.Contains(line, "{countries.name.list}") {
 			indent := getIndent(line)
-			countryList := generateCountryNameList(statsData.Countries, countriesMap, indent)
+			countryList := generateGroupNameList(groups, indent)
 			result = append(result, countryList...)
 			i++
 			continue
@@ -155,12 +194,57 @@ func processRuleContent(ruleContent string, statsData *StatsData, countriesMap m
 		// 处理 {countries.list}
 		if strings.Contains(line, "{countries.list}") {
 			indent := getIndent(line)
-			countryGroups := generateCountryGroups(statsData.Countries, countriesMap, indent)
+			countryGroups := generateGroupBlocks(groups, indent)
 			result = append(result, countryGroups...)
 			i++
 			continue
 		}
 
+		// 处理 {continents.name.list}
+		if strings.Contains(line, "{continents.name.list}") {
+			result = append(result, generateGroupNameList(continents, getIndent(line))...)
+			i++
+			continue
+		}
+
+		// 处理 {continents.list}
+		if strings.Contains(line, "{continents.list}") {
+			result = append(result, generateGroupBlocks(continents, getIndent(line))...)
+			i++
+			continue
+		}
+
+		// 处理 {sets.name.list}
+		if strings.Contains(line, "{sets.name.list}") {
+			result = append(result, generateGroupNameList(sets, getIndent(line))...)
+			i++
+			continue
+		}
+
+		// 处理 {sets.list}
+		if strings.Contains(line, "{sets.list}") {
+			result = append(result, generateGroupBlocks(sets, getIndent(line))...)
+			i++
+			continue
+		}
+
+		// 处理 {set.名称}，只生成指定的自定义国家组
+		if matches := setPlaceholderRegex.FindStringSubmatch(line); matches != nil {
+			found := false
+			for _, set := range sets {
+				if set.Name == matches[1] {
+					result = append(result, generateGroupBlocks([]countryGroup{set}, getIndent(line))...)
+					found = true
+					break
+				}
+			}
+			if !found && !slices.ContainsFunc(countrySetDefs(countriesMap), func(set config.CountrySet) bool { return set.Name == matches[1] }) {
+				slog.Warn(fmt.Sprintf("规则模板中的自定义国家组未定义: %s", matches[1]))
+			}
+			i++
+			continue
+		}
+
 		// 处理 {media.list}
 		if strings.Contains(line, "{media.list}") {
 			indent := getIndent(line)
@@ -196,35 +280,222 @@ func getIndent(line string) string {
 	return indent
 }
 
-// generateCountryNameList 生成国家名称列表
-func generateCountryNameList(countries map[string]int, countriesMap map[string]CountryInfo, indent string) []string {
-	var result []string
+// countryGroup 国家代理组
+type countryGroup struct {
+	Name   string
+	Filter string
+}
+
+// otherGroupName 合并小国家后的代理组名称
+const otherGroupName = "❓ 其他地区"
+
+// continentGroups 大洲代理组，按固定顺序生成
+var continentGroups = []struct {
+	Key  string
+	Name string
+}{
+	{"asia", "🌏 亚洲"},
+	{"europe", "🌍 欧洲"},
+	{"americas", "🌎 美洲"},
+	{"oceania", "🌏 大洋洲"},
+	{"africa", "🌍 非洲"},
+}
 
+// setPlaceholderRegex 匹配 {set.名称} 占位符
+var setPlaceholderRegex = regexp.MustCompile(`\{set\.([^{}]+)\}`)
+
+// countryPattern 生成匹配单个国家节点名称的正则片段
+func countryPattern(code string, country CountryInfo) string {
+	return fmt.Sprintf("%s|%s|%s_|%s", country.Flag, country.CnName, code, country.EnName)
+}
+
+// buildCountryGroups 按配置排序、过滤并合并国家代理组，保证每次生成的顺序一致
+func buildCountryGroups(countries map[string]int, countriesMap map[string]CountryInfo) []countryGroup {
+	minNodes := config.GlobalConfig.CountryGroupMinNodes
+
+	var codes []string
+	var others []string
+	for code, count := range countries {
+		if _, ok := countriesMap[code]; ok && count >= minNodes {
+			codes = append(codes, code)
+		} else {
+			others = append(others, code)
+		}
+	}
+	sortCountryCodes(codes, countries)
+	sort.Strings(others)
+
+	groups := make([]countryGroup, 0, len(codes)+1)
+	for _, code := range codes {
+		country := countriesMap[code]
+		groups = append(groups, countryGroup{
+			Name:   fmt.Sprintf("%s %s", country.Flag, country.CnName),
+			Filter: "(?i)" + countryPattern(code, country),
+		})
+	}
+
+	if config.GlobalConfig.CountryGroupOther && len(others) > 0 {
+		// 重命名时未识别的国家会以 ❓Other 开头
+		patterns := []string{"❓Other"}
+		for _, code := range others {
+			patterns = append(patterns, code+"_")
+		}
+		groups = append(groups, countryGroup{
+			Name:   otherGroupName,
+			Filter: "(?i)" + strings.Join(patterns, "|"),
+		})
+	}
+
+	return groups
+}
+
+// buildContinentGroups 按 countries.json 中的大洲信息生成大洲代理组
+// 只包含本次有节点的国家，没有节点的大洲不生成
+func buildContinentGroups(countries map[string]int, countriesMap map[string]CountryInfo) []countryGroup {
+	members := make(map[string][]string)
 	for code := range countries {
-		if country, ok := countriesMap[code]; ok {
-			result = append(result, fmt.Sprintf("%s- %s %s", indent, country.Flag, country.CnName))
+		if country, ok := countriesMap[code]; ok && country.Continent != "" {
+			members[country.Continent] = append(members[country.Continent], code)
+		}
+	}
+
+	var groups []countryGroup
+	for _, continent := range continentGroups {
+		codes := members[continent.Key]
+		if len(codes) == 0 {
+			continue
+		}
+		sort.Strings(codes)
+
+		patterns := make([]string, 0, len(codes))
+		for _, code := range codes {
+			patterns = append(patterns, countryPattern(code, countriesMap[code]))
+		}
+		groups = append(groups, countryGroup{
+			Name:   continent.Name,
+			Filter: "(?i)" + strings.Join(patterns, "|"),
+		})
+	}
+
+	return groups
+}
+
+// countrySetDefs 合并 country-sets 配置和 countries.json 中 sets 字段定义的国家组
+// 配置中的组在前，countries.json 中的组按名称排序，同名时以配置为准
+func countrySetDefs(countriesMap map[string]CountryInfo) []config.CountrySet {
+	defs := slices.Clone(config.GlobalConfig.CountrySets)
+	defined := make(map[string]bool, len(defs))
+	for _, set := range defs {
+		defined[set.Name] = true
+	}
+
+	fromMeta := make(map[string][]string)
+	for code, country := range countriesMap {
+		for _, name := range country.Sets {
+			name = strings.TrimSpace(name)
+			if name != "" && !defined[name] {
+				fromMeta[name] = append(fromMeta[name], code)
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(fromMeta)) {
+		codes := fromMeta[name]
+		slices.Sort(codes)
+		defs = append(defs, config.CountrySet{Name: name, Countries: codes})
+	}
+	return defs
+}
+
+// buildCountrySets 生成自定义国家组，顺序见 countrySetDefs
+// 与大洲代理组相同，只包含本次有节点的国家，没有节点的组不生成，避免生成空的代理组
+func buildCountrySets(countries map[string]int, countriesMap map[string]CountryInfo) []countryGroup {
+	var groups []countryGroup
+	for _, set := range countrySetDefs(countriesMap) {
+		if set.Name == "" {
+			continue
 		}
+
+		var patterns []string
+		for _, code := range set.Countries {
+			code = strings.ToUpper(strings.TrimSpace(code))
+			country, ok := countriesMap[code]
+			if !ok {
+				slog.Warn(fmt.Sprintf("自定义国家组 %s 中的国家代码未知: %s", set.Name, code))
+				continue
+			}
+			if countries[code] > 0 {
+				patterns = append(patterns, countryPattern(code, country))
+			}
+		}
+		if len(patterns) == 0 {
+			slog.Debug(fmt.Sprintf("自定义国家组 %s 本次没有节点，跳过生成", set.Name))
+			continue
+		}
+
+		groups = append(groups, countryGroup{
+			Name:   set.Name,
+			Filter: "(?i)" + strings.Join(patterns, "|"),
+		})
+	}
+	return groups
+}
+
+// sortCountryCodes 对国家代码排序
+// 置顶国家按配置顺序排在最前，其余按 country-group-sort 排序:
+// - count: 按节点数量降序(默认)
+// - alpha: 按国家代码字母顺序
+func sortCountryCodes(codes []string, countries map[string]int) {
+	pinned := make(map[string]int, len(config.GlobalConfig.CountryGroupPinned))
+	for i, code := range config.GlobalConfig.CountryGroupPinned {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if _, ok := pinned[code]; !ok {
+			pinned[code] = i
+		}
+	}
+
+	sort.SliceStable(codes, func(i, j int) bool {
+		a, b := codes[i], codes[j]
+		pa, aPinned := pinned[a]
+		pb, bPinned := pinned[b]
+		if aPinned || bPinned {
+			if aPinned && bPinned {
+				return pa < pb
+			}
+			return aPinned
+		}
+
+		if config.GlobalConfig.CountryGroupSort != "alpha" && countries[a] != countries[b] {
+			return countries[a] > countries[b]
+		}
+		return a < b
+	})
+}
+
+// generateGroupNameList 生成代理组名称列表
+func generateGroupNameList(groups []countryGroup, indent string) []string {
+	var result []string
+
+	for _, group := range groups {
+		result = append(result, fmt.Sprintf("%s- %s", indent, group.Name))
 	}
 
 	return result
 }
 
-// generateCountryGroups 生成国家代理组
-func generateCountryGroups(countries map[string]int, countriesMap map[string]CountryInfo, indent string) []string {
+// generateGroupBlocks 生成代理组定义
+func generateGroupBlocks(groups []countryGroup, indent string) []string {
 	var result []string
 
-	for code := range countries {
-		if country, ok := countriesMap[code]; ok {
-			group := fmt.Sprintf(
-				"%s- name: %s %s\n%s  include-all: true\n%s  filter: (?i)%s|%s|%s_|%s\n%s  type: url-test\n%s  interval: 300\n%s  tolerance: 50",
-				indent, country.Flag, country.CnName,
-				indent,
-				indent, country.Flag, country.CnName, code, country.EnName,
-				indent,
-				indent,
-				indent)
-			result = append(result, strings.Split(group, "\n")...)
-		}
+	for _, group := range groups {
+		block := fmt.Sprintf(
+			"%s- name: %s\n%s  include-all: true\n%s  filter: %s\n%s  type: url-test\n%s  interval: 300\n%s  tolerance: 50",
+			indent, group.Name,
+			indent,
+			indent, group.Filter,
+			indent,
+			indent,
+			indent)
+		result = append(result, strings.Split(block, "\n")...)
 	}
 
 	return result
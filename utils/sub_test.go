@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/beck-8/subs-check/config"
+)
+
+// testCountries 测试用的 countries.json 内容
+var testCountries = map[string]CountryInfo{
+	"HK": {CnName: "香港", EnName: "Hong Kong", Flag: "🇭🇰", Continent: "asia"},
+	"JP": {CnName: "日本", EnName: "Japan", Flag: "🇯🇵", Continent: "asia"},
+	"US": {CnName: "美国", EnName: "United States", Flag: "🇺🇸", Continent: "americas"},
+	"DE": {CnName: "德国", EnName: "Germany", Flag: "🇩🇪", Continent: "europe"},
+}
+
+func groupNames(groups []countryGroup) []string {
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	return names
+}
+
+func TestBuildCountryGroups(t *testing.T) {
+	saved := *config.GlobalConfig
+	t.Cleanup(func() { *config.GlobalConfig = saved })
+
+	countries := map[string]int{"HK": 5, "JP": 9, "US": 5, "DE": 1, "XX": 3}
+	tests := []struct {
+		name     string
+		sort     string
+		pinned   []string
+		minNodes int
+		other    bool
+		want     []string
+	}{
+		{
+			name: "按节点数量排序",
+			want: []string{"🇯🇵 日本", "🇭🇰 香港", "🇺🇸 美国", "🇩🇪 德国"},
+		},
+		{
+			name: "按字母排序",
+			sort: "alpha",
+			want: []string{"🇩🇪 德国", "🇭🇰 香港", "🇯🇵 日本", "🇺🇸 美国"},
+		},
+		{
+			name:   "置顶国家按配置顺序",
+			pinned: []string{"us", " DE ", "US"},
+			want:   []string{"🇺🇸 美国", "🇩🇪 德国", "🇯🇵 日本", "🇭🇰 香港"},
+		},
+		{
+			name:     "节点少的国家不单独分组",
+			minNodes: 2,
+			want:     []string{"🇯🇵 日本", "🇭🇰 香港", "🇺🇸 美国"},
+		},
+		{
+			name:     "合并到其他地区",
+			minNodes: 2,
+			other:    true,
+			want:     []string{"🇯🇵 日本", "🇭🇰 香港", "🇺🇸 美国", otherGroupName},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.GlobalConfig.CountryGroupSort = tt.sort
+			config.GlobalConfig.CountryGroupPinned = tt.pinned
+			config.GlobalConfig.CountryGroupMinNodes = tt.minNodes
+			config.GlobalConfig.CountryGroupOther = tt.other
+
+			// map 遍历顺序随机，多次生成的结果必须一致
+			for range 10 {
+				if got := groupNames(buildCountryGroups(countries, testCountries)); !slices.Equal(got, tt.want) {
+					t.Fatalf("buildCountryGroups() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	config.GlobalConfig.CountryGroupPinned = nil
+	config.GlobalConfig.CountryGroupMinNodes = 2
+	config.GlobalConfig.CountryGroupOther = true
+	groups := buildCountryGroups(countries, testCountries)
+	if want := "(?i)❓Other|DE_|XX_"; groups[len(groups)-1].Filter != want {
+		t.Errorf("其他地区 filter = %q, want %q", groups[len(groups)-1].Filter, want)
+	}
+}
+
+func TestBuildCountrySets(t *testing.T) {
+	saved := config.GlobalConfig.CountrySets
+	t.Cleanup(func() { config.GlobalConfig.CountrySets = saved })
+	config.GlobalConfig.CountrySets = []config.CountrySet{
+		{Name: "亚洲", Countries: []string{"hk", "JP", "XX"}},
+		{Name: "欧洲", Countries: []string{"DE"}},
+		{Name: "未知", Countries: []string{"XX"}},
+	}
+
+	// 德国本次没有节点，欧洲组不生成；日本没有节点，不出现在亚洲组的 filter 中
+	groups := buildCountrySets(map[string]int{"HK": 2, "US": 1}, testCountries)
+	if got := groupNames(groups); !slices.Equal(got, []string{"亚洲"}) {
+		t.Fatalf("buildCountrySets() = %v, want [亚洲]", got)
+	}
+	if want := "(?i)" + countryPattern("HK", testCountries["HK"]); groups[0].Filter != want {
+		t.Errorf("亚洲 filter = %q, want %q", groups[0].Filter, want)
+	}
+
+	if groups := buildCountrySets(map[string]int{}, testCountries); len(groups) != 0 {
+		t.Errorf("没有节点时 buildCountrySets() = %v, want 空", groupNames(groups))
+	}
+}
+
+func TestLoadRuleTemplate(t *testing.T) {
+	saved := *config.GlobalConfig
+	t.Cleanup(func() { *config.GlobalConfig = saved })
+	config.GlobalConfig.SubUrlsReTry = 1
+
+	// 本地路径
+	path := filepath.Join(t.TempDir(), "rule.yaml")
+	if err := os.WriteFile(path, []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := loadRuleTemplate(config.RuleTemplate{Name: "local.yaml", Template: path})
+	if err != nil || string(data) != "local" {
+		t.Errorf("本地模板 loadRuleTemplate() = %q, %v", data, err)
+	}
+	if _, err := loadRuleTemplate(config.RuleTemplate{Name: "missing.yaml", Template: path + ".missing"}); err == nil {
+		t.Errorf("本地模板不存在时 loadRuleTemplate() error = nil")
+	}
+
+	// 远程链接，下载成功后缓存
+	name := fmt.Sprintf("test-%d.yaml", time.Now().UnixNano())
+	cachePath := filepath.Join(GetConfigDir(), "cache", "rule-"+name)
+	t.Cleanup(func() { os.Remove(cachePath) })
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("remote"))
+	}))
+	tpl := config.RuleTemplate{Name: name, Template: server.URL + "/rule.yaml"}
+	data, err = loadRuleTemplate(tpl)
+	if err != nil || string(data) != "remote" {
+		t.Errorf("远程模板 loadRuleTemplate() = %q, %v", data, err)
+	}
+	if cached, err := os.ReadFile(cachePath); err != nil || string(cached) != "remote" {
+		t.Errorf("远程模板缓存 = %q, %v", cached, err)
+	}
+
+	// 下载失败时使用缓存
+	server.Close()
+	data, err = loadRuleTemplate(tpl)
+	if err != nil || string(data) != "remote" {
+		t.Errorf("下载失败时 loadRuleTemplate() = %q, %v, want 缓存内容", data, err)
+	}
+
+	// 下载失败且没有缓存
+	tpl.Name = "no-cache-" + name
+	if _, err := loadRuleTemplate(tpl); err == nil {
+		t.Errorf("下载失败且没有缓存时 loadRuleTemplate() error = nil")
+	}
+}
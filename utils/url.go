@@ -1,6 +1,7 @@
 package utils
 
 import (
+	u "net/url"
 	"path/filepath"
 	"strings"
 	"time"
@@ -45,3 +46,15 @@ func GetConfigDir() string {
 	configDir := filepath.Join(basePath, "config")
 	return configDir
 }
+
+// SubscriptionName 订阅的展示名称，有备注时使用备注，否则只使用域名
+// 统计数据和事件可能被公开访问，避免泄露订阅链接中的token
+func SubscriptionName(subURL, tag string) string {
+	if tag != "" {
+		return tag
+	}
+	if d, err := u.Parse(subURL); err == nil {
+		return d.Host
+	}
+	return ""
+}
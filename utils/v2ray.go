@@ -6,38 +6,36 @@ import (
 	"fmt"
 	"log/slog"
 	"net/url"
-	"os"
-	"path/filepath"
 	"regexp"
 	"strings"
 
 	"github.com/beck-8/subs-check/config"
-	"gopkg.in/yaml.v3"
 )
 
-// ConvertToV2Ray 将 node.yaml 转换为 V2Ray 订阅格式
-func ConvertToV2Ray(outputPath string) error {
+// ConvertToV2Ray 将节点转换为 V2Ray 订阅内容，未开启转换或没有可转换的节点时返回 nil
+func ConvertToV2Ray(proxies []map[string]any) []byte {
 	// 检查配置开关
 	if !config.GlobalConfig.V2RaySubscription {
 		slog.Debug("V2Ray 订阅转换已禁用 跳过")
 		return nil
 	}
 
-	allYamlPath := filepath.Join(outputPath, "node.yaml")
-	yamlData, err := os.ReadFile(allYamlPath)
-	if err != nil {
-		return fmt.Errorf("读取 node.yaml 失败: %w", err)
-	}
+	v2rayLinks := ProxiesToV2RayLinks(proxies)
 
-	var yamlConfig struct {
-		Proxies []map[string]any `yaml:"proxies"`
-	}
-	if err := yaml.Unmarshal(yamlData, &yamlConfig); err != nil {
-		return fmt.Errorf("解析 YAML 失败: %w", err)
+	if len(v2rayLinks) == 0 {
+		slog.Warn("没有可转换的 V2Ray 节点")
+		return nil
 	}
 
+	slog.Info("V2Ray 订阅转换成功", "节点数", len(v2rayLinks))
+	// 直接保存链接列表,不进行 Base64 编码
+	return []byte(strings.Join(v2rayLinks, "\n"))
+}
+
+// ProxiesToV2RayLinks 将节点转换为 V2Ray 分享链接，不支持的协议会被跳过
+func ProxiesToV2RayLinks(proxies []map[string]any) []string {
 	var v2rayLinks []string
-	for _, proxy := range yamlConfig.Proxies {
+	for _, proxy := range proxies {
 		proxyType, _ := proxy["type"].(string)
 
 		var link string
@@ -58,22 +56,7 @@ func ConvertToV2Ray(outputPath string) error {
 			v2rayLinks = append(v2rayLinks, link)
 		}
 	}
-
-	if len(v2rayLinks) == 0 {
-		slog.Warn("没有可转换的 V2Ray 节点")
-		return nil
-	}
-
-	// 直接保存链接列表,不进行 Base64 编码
-	v2rayContent := strings.Join(v2rayLinks, "\n")
-
-	v2rayPath := filepath.Join(outputPath, "v2ray.txt")
-	if err := os.WriteFile(v2rayPath, []byte(v2rayContent), 0644); err != nil {
-		return fmt.Errorf("保存 V2Ray 订阅失败: %w", err)
-	}
-
-	slog.Info("V2Ray 订阅转换成功", "path", v2rayPath, "节点数", len(v2rayLinks))
-	return nil
+	return v2rayLinks
 }
 
 // removeFlagEmoji 移除 Unicode 旗帜字符(仅移除开头的旗帜)
@@ -368,4 +351,4 @@ func getALPN(m map[string]any) string {
 		return strings.Join(alpns, ",")
 	}
 	return ""
-}
\ No newline at end of file
+}
\ No newline at end of file